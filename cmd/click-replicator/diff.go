@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+func runDiff(args []string) int {
+	fs := newFlagSet("diff")
+	job := newJobFlags(fs)
+	migration := fs.String("migration", "", "also write the migration script to this file")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	replicator, err := job.replicator()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diff:", err)
+		return exitUsage
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	diff, err := replicator.Diff(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diff:", err)
+		return exitFailure
+	}
+	if err := job.write(os.Stdout, diff); err != nil {
+		fmt.Fprintln(os.Stderr, "diff:", err)
+		return exitFailure
+	}
+	if *migration != "" {
+		if err := writeMigration(*migration, diff); err != nil {
+			fmt.Fprintln(os.Stderr, "diff:", err)
+			return exitFailure
+		}
+	}
+	if !diff.Match {
+		return exitMismatch
+	}
+	return exitOK
+}
+
+// writeMigration writes the migration script of diff to path.
+func writeMigration(path string, diff *models.SchemaDiff) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := diff.WriteMigration(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
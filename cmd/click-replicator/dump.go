@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	clickreplicator "github.com/prasannakumar414/click-replicator"
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+func runExport(args []string) int {
+	return runDump("export", args, (*clickreplicator.ClickReplicator).Export)
+}
+
+func runImport(args []string) int {
+	return runDump("import", args, (*clickreplicator.ClickReplicator).Import)
+}
+
+// runDump runs export or import, which share their flags and outcome.
+func runDump(name string, args []string, run func(*clickreplicator.ClickReplicator, context.Context, string) (*models.DumpResult, error)) int {
+	fs := newFlagSet(name)
+	job := newJobFlags(fs)
+	dir := fs.String("dir", "", "directory holding the <table>.jsonl and <table>.sql files")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if *dir == "" {
+		fmt.Fprintf(os.Stderr, "%s: -dir is required\n", name)
+		return exitUsage
+	}
+	replicator, err := job.replicator()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitUsage
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	result, err := run(replicator, ctx, *dir)
+	if result != nil {
+		if writeErr := job.write(os.Stdout, result); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, writeErr)
+			return exitFailure
+		}
+	}
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		fmt.Fprintf(os.Stderr, "%s: interrupted\n", name)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitFailure
+	}
+	if result.Failed() > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d tables failed\n", name, result.Failed())
+		return exitPartial
+	}
+	return exitOK
+}
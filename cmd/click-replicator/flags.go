@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	clickreplicator "github.com/prasannakumar414/click-replicator"
+	"github.com/prasannakumar414/click-replicator/config"
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/checkpoint"
+	"github.com/prasannakumar414/click-replicator/services/watermark"
+)
+
+// connectionFlags holds the flags describing one ClickHouse server, all
+// prefixed with prefix such as "source" or "destination".
+type connectionFlags struct {
+	prefix    string
+	config    models.ClickHouseConfig
+	addresses stringList
+	settings  stringList
+	useTLS    bool
+	tls       models.TLSConfig
+	// passwordFrom holds the -password-env, -password-file and
+	// -password-command flags.
+	passwordFrom    models.SecretSource
+	passwordCommand string
+}
+
+func newConnectionFlags(fs *flag.FlagSet, prefix string) *connectionFlags {
+	c := &connectionFlags{prefix: prefix}
+	fs.StringVar(&c.config.Host, prefix+"-host", "localhost", prefix+" host")
+	fs.IntVar(&c.config.Port, prefix+"-port", 9000, prefix+" port")
+	fs.StringVar(&c.config.Username, prefix+"-user", "default", prefix+" user")
+	fs.StringVar(&c.config.Password, prefix+"-password", "", prefix+" password")
+	fs.StringVar(&c.passwordFrom.Env, prefix+"-password-env", "", "read the "+prefix+" password from this environment variable")
+	fs.StringVar(&c.passwordFrom.File, prefix+"-password-file", "", "read the "+prefix+" password from this file")
+	fs.StringVar(&c.passwordCommand, prefix+"-password-command", "", "read the "+prefix+" password from the output of this command, split on spaces and run without a shell")
+	fs.StringVar(&c.config.Database, prefix+"-database", "default", prefix+" database")
+	fs.Var(&c.addresses, prefix+"-addresses", prefix+" host:port addresses to dial instead of host and port (repeatable)")
+	fs.StringVar((*string)(&c.config.ConnOpenStrategy), prefix+"-conn-open-strategy", "", prefix+" address choice: in-order, round-robin or random")
+	fs.StringVar((*string)(&c.config.Protocol), prefix+"-protocol", "", prefix+" protocol: native or http")
+	fs.StringVar(&c.config.Compression, prefix+"-compression", "", prefix+" compression: lz4, lz4hc, zstd, gzip, deflate, br or none")
+	fs.Var(&c.settings, prefix+"-setting", prefix+" session setting as name=value (repeatable)")
+	fs.BoolVar(&c.useTLS, prefix+"-tls", false, "connect to the "+prefix+" over TLS")
+	fs.StringVar(&c.tls.CAFile, prefix+"-tls-ca", "", prefix+" CA certificate file; implies -"+prefix+"-tls")
+	fs.StringVar(&c.tls.CertFile, prefix+"-tls-cert", "", prefix+" client certificate file; implies -"+prefix+"-tls")
+	fs.StringVar(&c.tls.KeyFile, prefix+"-tls-key", "", prefix+" client key file; implies -"+prefix+"-tls")
+	fs.StringVar(&c.tls.ServerName, prefix+"-tls-server-name", "", prefix+" server name to verify; implies -"+prefix+"-tls")
+	fs.BoolVar(&c.tls.InsecureSkipVerify, prefix+"-tls-insecure-skip-verify", false, "do not verify the "+prefix+" certificate; implies -"+prefix+"-tls")
+	return c
+}
+
+// apply copies the flags into config: all of them when all is true,
+// otherwise only the ones set on the command line.
+func (c *connectionFlags) apply(config *models.ClickHouseConfig, set map[string]bool, all bool) error {
+	given := func(name string) bool {
+		return all || set[c.prefix+"-"+name]
+	}
+	if given("host") {
+		config.Host = c.config.Host
+	}
+	if given("port") {
+		config.Port = c.config.Port
+	}
+	if given("user") {
+		config.Username = c.config.Username
+	}
+	if given("password") {
+		config.Password = c.config.Password
+	}
+	if given("database") {
+		config.Database = c.config.Database
+	}
+	if set[c.prefix+"-password-env"] || set[c.prefix+"-password-file"] || set[c.prefix+"-password-command"] {
+		from := c.passwordFrom
+		from.Command = strings.Fields(c.passwordCommand)
+		config.PasswordFrom = &from
+		// A literal password from the job file gives way to the flag.
+		if !set[c.prefix+"-password"] {
+			config.Password = ""
+		}
+	}
+	if given("addresses") {
+		config.Addresses = c.addresses
+	}
+	if given("conn-open-strategy") {
+		config.ConnOpenStrategy = c.config.ConnOpenStrategy
+	}
+	if given("protocol") {
+		config.Protocol = c.config.Protocol
+	}
+	if given("compression") {
+		config.Compression = c.config.Compression
+	}
+	for _, setting := range c.settings {
+		name, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return fmt.Errorf("-%s-setting %q: expected name=value", c.prefix, setting)
+		}
+		if config.Settings == nil {
+			config.Settings = make(map[string]any)
+		}
+		config.Settings[name] = value
+	}
+	tlsFlags := []string{"tls", "tls-ca", "tls-cert", "tls-key", "tls-server-name", "tls-insecure-skip-verify"}
+	for _, name := range tlsFlags {
+		if set[c.prefix+"-"+name] {
+			if config.TLS == nil {
+				config.TLS = &models.TLSConfig{}
+			}
+			break
+		}
+	}
+	if config.TLS != nil {
+		if set[c.prefix+"-tls-ca"] {
+			config.TLS.CAFile = c.tls.CAFile
+		}
+		if set[c.prefix+"-tls-cert"] {
+			config.TLS.CertFile = c.tls.CertFile
+		}
+		if set[c.prefix+"-tls-key"] {
+			config.TLS.KeyFile = c.tls.KeyFile
+		}
+		if set[c.prefix+"-tls-server-name"] {
+			config.TLS.ServerName = c.tls.ServerName
+		}
+		if set[c.prefix+"-tls-insecure-skip-verify"] {
+			config.TLS.InsecureSkipVerify = c.tls.InsecureSkipVerify
+		}
+	}
+	return nil
+}
+
+// stringList is a flag that may be repeated and also accepts comma separated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// jobFlags holds the flags shared by every command: an optional job file,
+// both servers, the table selection and the output format. Flags given on the
+// command line win over the job file.
+type jobFlags struct {
+	fs          *flag.FlagSet
+	configFile  string
+	source      *connectionFlags
+	destination *connectionFlags
+	tables      *tableFlags
+	engines     stringList
+	cluster     *clusterFlags
+	asJSON      bool
+}
+
+func newJobFlags(fs *flag.FlagSet) *jobFlags {
+	j := &jobFlags{fs: fs}
+	fs.StringVar(&j.configFile, "config", "", "job file in YAML or JSON; flags given on the command line override it")
+	j.source = newConnectionFlags(fs, "source")
+	j.destination = newConnectionFlags(fs, "destination")
+	j.tables = newTableFlags(fs)
+	j.cluster = newClusterFlags(fs)
+	fs.Var(&j.engines, "engine-preset", "rewrite the engine of created tables with a preset: replicated-to-plain or plain-to-replicated (repeatable)")
+	fs.BoolVar(&j.asJSON, "json", false, "print the outcome as JSON")
+	return j
+}
+
+// replicator builds the ClickReplicator described by the job file and the
+// flags, followed by extra options.
+func (j *jobFlags) replicator(extra ...clickreplicator.Option) (*clickreplicator.ClickReplicator, error) {
+	set := make(map[string]bool)
+	j.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var (
+		source, destination models.ClickHouseConfig
+		cluster             models.ClusterConfig
+		opts                []clickreplicator.Option
+	)
+	if j.configFile != "" {
+		job, err := config.Load(j.configFile)
+		if err != nil {
+			return nil, err
+		}
+		source, destination, cluster = job.Source, job.Destination, job.DestinationCluster
+		opts = clickreplicator.JobOptions(job)
+	}
+	// Without a job file every connection flag applies, defaults included.
+	all := j.configFile == ""
+	if err := j.source.apply(&source, set, all); err != nil {
+		return nil, err
+	}
+	if err := j.destination.apply(&destination, set, all); err != nil {
+		return nil, err
+	}
+	tableOpts, err := j.tables.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, tableOpts...)
+	if j.cluster.apply(&cluster, set) {
+		opts = append(opts, clickreplicator.WithDestinationCluster(cluster))
+	}
+	if len(j.engines) > 0 {
+		opts = append(opts, clickreplicator.WithEnginePresets(j.engines...))
+	}
+	opts = append(opts, extra...)
+	return clickreplicator.NewClickReplicator(source, destination, opts...), nil
+}
+
+// write prints an outcome as JSON or as text depending on -json.
+func (j *jobFlags) write(w io.Writer, outcome interface{ WriteText(io.Writer) error }) error {
+	if j.asJSON {
+		return writeJSON(w, outcome)
+	}
+	return outcome.WriteText(w)
+}
+
+// clusterFlags holds the flags running the destination DDL on a cluster.
+type clusterFlags struct {
+	config  models.ClusterConfig
+	timeout time.Duration
+}
+
+func newClusterFlags(fs *flag.FlagSet) *clusterFlags {
+	c := &clusterFlags{}
+	fs.StringVar(&c.config.Name, "destination-cluster", "", "run destination DDL ON CLUSTER this cluster")
+	fs.DurationVar(&c.timeout, "ddl-timeout", 0, "how long DDL on the destination cluster waits for every host (distributed_ddl_task_timeout)")
+	fs.BoolVar(&c.config.DistributedTables, "distributed-tables", false, "create a Distributed table over every table on the destination cluster")
+	fs.StringVar(&c.config.DistributedSuffix, "distributed-suffix", "", "name suffix of the Distributed tables (default "+models.DefaultDistributedSuffix+")")
+	return c
+}
+
+// apply copies the cluster flags set on the command line into config and
+// tells whether there were any.
+func (c *clusterFlags) apply(config *models.ClusterConfig, set map[string]bool) bool {
+	given := false
+	if set["destination-cluster"] {
+		config.Name, given = c.config.Name, true
+	}
+	if set["ddl-timeout"] {
+		config.DDLTimeout, given = models.Duration(c.timeout), true
+	}
+	if set["distributed-tables"] {
+		config.DistributedTables, given = c.config.DistributedTables, true
+	}
+	if set["distributed-suffix"] {
+		config.DistributedSuffix, given = c.config.DistributedSuffix, true
+	}
+	return given
+}
+
+// tableFlags holds the flags selecting and renaming tables.
+type tableFlags struct {
+	include  stringList
+	exclude  stringList
+	pairs    stringList
+	rewrites stringList
+	template string
+}
+
+func newTableFlags(fs *flag.FlagSet) *tableFlags {
+	t := &tableFlags{}
+	fs.Var(&t.include, "include", "only tables matching this glob, or regular expression prefixed with re: (repeatable)")
+	fs.Var(&t.exclude, "exclude", "skip tables matching this glob, or regular expression prefixed with re: (repeatable)")
+	fs.Var(&t.pairs, "map", "rename a table on the destination, as source=destination (repeatable)")
+	fs.Var(&t.rewrites, "rewrite", "rename the tables matching a regular expression, as pattern=replacement (repeatable)")
+	fs.StringVar(&t.template, "table-template", "", "destination table name template, such as stg_{table}")
+	return t
+}
+
+// options returns the options of the table flags. The mapping is only set
+// when a mapping flag is given, so it does not replace the job file's.
+func (t *tableFlags) options() ([]clickreplicator.Option, error) {
+	opts := []clickreplicator.Option{
+		clickreplicator.WithIncludeTables(t.include...),
+		clickreplicator.WithExcludeTables(t.exclude...),
+	}
+	if len(t.pairs) == 0 && len(t.rewrites) == 0 && t.template == "" {
+		return opts, nil
+	}
+	mapping := models.TableMapping{Template: t.template}
+	for _, pair := range t.pairs {
+		source, destination, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("-map %q: expected source=destination", pair)
+		}
+		if mapping.Tables == nil {
+			mapping.Tables = make(map[string]string)
+		}
+		mapping.Tables[source] = destination
+	}
+	for _, rewrite := range t.rewrites {
+		pattern, replacement, ok := strings.Cut(rewrite, "=")
+		if !ok {
+			return nil, fmt.Errorf("-rewrite %q: expected pattern=replacement", rewrite)
+		}
+		mapping.Rewrites = append(mapping.Rewrites, models.Rewrite{Pattern: pattern, Replacement: replacement})
+	}
+	return append(opts, clickreplicator.WithTableMapping(mapping)), nil
+}
+
+// runFlags holds the flags tuning how a replication run copies tables. Only
+// the flags given on the command line become options, so they override the
+// job file without resetting what it sets.
+type runFlags struct {
+	fs               *flag.FlagSet
+	transfer         string
+	stagingDir       string
+	concurrency      int
+	order            string
+	maxSourceQueries int
+	retries          int
+	chunkRows        uint64
+	strict           bool
+	watermarkFile    string
+	checkpointFile   string
+}
+
+func newRunFlags(fs *flag.FlagSet) *runFlags {
+	r := &runFlags{fs: fs}
+	fs.StringVar(&r.transfer, "transfer", "", "how rows are copied: native (default) or client (clickhouse-client)")
+	fs.StringVar(&r.stagingDir, "staging-dir", "", "directory for the files of the client transfer (default the working directory)")
+	fs.IntVar(&r.concurrency, "concurrency", 0, "tables replicated at once (default 1)")
+	fs.StringVar(&r.order, "order", "", "which tables start first: largest-first or smallest-first (default source order)")
+	fs.IntVar(&r.maxSourceQueries, "max-source-queries", 0, "table reads running on the source at once, regardless of -concurrency (default no limit)")
+	fs.IntVar(&r.retries, "retries", 3, "further attempts for each failed partition or chunk")
+	fs.Uint64Var(&r.chunkRows, "chunk-rows", 0, "split tables with more rows than this into sorting key ranges (default no chunks)")
+	fs.BoolVar(&r.strict, "strict", false, "report the errors of every failed table as the error of the run")
+	fs.StringVar(&r.watermarkFile, "watermark-file", "", "file keeping the cursor column watermarks (default "+watermark.DefaultFileName+")")
+	fs.StringVar(&r.checkpointFile, "checkpoint-file", "", "file keeping the checkpoints of an interrupted run (default "+checkpoint.DefaultFileName+")")
+	return r
+}
+
+// options returns the options of the run flags given on the command line.
+func (r *runFlags) options() ([]clickreplicator.Option, error) {
+	set := make(map[string]bool)
+	r.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var opts []clickreplicator.Option
+	if set["transfer"] {
+		switch method := models.TransferMethod(r.transfer); method {
+		case models.TransferNative, models.TransferClient:
+			opts = append(opts, clickreplicator.WithTransferMethod(method))
+		default:
+			return nil, fmt.Errorf("-transfer %q: expected native or client", r.transfer)
+		}
+	}
+	if r.stagingDir != "" {
+		opts = append(opts, clickreplicator.WithStagingDir(r.stagingDir))
+	}
+	if set["concurrency"] {
+		if r.concurrency < 1 {
+			return nil, fmt.Errorf("-concurrency %d: must be at least 1", r.concurrency)
+		}
+		opts = append(opts, clickreplicator.WithConcurrency(r.concurrency))
+	}
+	if set["order"] {
+		switch order := models.TableOrder(r.order); order {
+		case models.OrderByName, models.OrderLargestFirst, models.OrderSmallestFirst:
+			opts = append(opts, clickreplicator.WithTableOrder(order))
+		default:
+			return nil, fmt.Errorf("-order %q: expected largest-first or smallest-first", r.order)
+		}
+	}
+	if set["max-source-queries"] {
+		if r.maxSourceQueries < 0 {
+			return nil, fmt.Errorf("-max-source-queries %d: must not be negative", r.maxSourceQueries)
+		}
+		opts = append(opts, clickreplicator.WithMaxSourceQueries(r.maxSourceQueries))
+	}
+	if set["retries"] {
+		if r.retries < 0 {
+			return nil, fmt.Errorf("-retries %d: must not be negative", r.retries)
+		}
+		opts = append(opts, clickreplicator.WithRetries(r.retries))
+	}
+	if set["chunk-rows"] {
+		opts = append(opts, clickreplicator.WithChunkRows(r.chunkRows))
+	}
+	if r.strict {
+		opts = append(opts, clickreplicator.WithStrict())
+	}
+	if r.watermarkFile != "" {
+		opts = append(opts, clickreplicator.WithWatermarkFile(r.watermarkFile))
+	}
+	if r.checkpointFile != "" {
+		opts = append(opts, clickreplicator.WithCheckpointFile(r.checkpointFile))
+	}
+	return opts, nil
+}
+
+// schemaPolicyFlag defines -schema-policy and returns the options it sets.
+func schemaPolicyFlag(fs *flag.FlagSet) func() ([]clickreplicator.Option, error) {
+	policy := fs.String("schema-policy", "", "when source columns changed: warn (default), apply or fail")
+	return func() ([]clickreplicator.Option, error) {
+		switch models.SchemaPolicy(*policy) {
+		case "":
+			return nil, nil
+		case models.SchemaWarn, models.SchemaApply, models.SchemaFail:
+			return []clickreplicator.Option{clickreplicator.WithSchemaPolicy(models.SchemaPolicy(*policy))}, nil
+		default:
+			return nil, fmt.Errorf("-schema-policy %q: expected warn, apply or fail", *policy)
+		}
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseFlags parses args and maps a parse failure to an exit code. It
+// returns -1 when the command should go on.
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %v\n", fs.Args())
+		return exitUsage
+	}
+	return -1
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,96 @@
+// Command click-replicator replicates, compares and inspects ClickHouse
+// databases from the command line.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit codes shared by every subcommand.
+const (
+	exitOK = 0
+	// exitFailure means the command could not do its work, for example
+	// because a server was unreachable.
+	exitFailure = 1
+	// exitUsage means invalid flags, arguments or job file.
+	exitUsage = 2
+	// exitMismatch means verify or diff found differences.
+	exitMismatch = 3
+	// exitPartial means the command ran but some tables failed.
+	exitPartial = 4
+	// exitInterrupted means the command stopped on SIGINT or SIGTERM.
+	exitInterrupted = 130
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{name: "replicate", summary: "copy the source database to the destination", run: runReplicate},
+	{name: "plan", summary: "show what a replication would do without writing anything", run: runPlan},
+	{name: "verify", summary: "compare row counts and content hashes of source and destination", run: runVerify},
+	{name: "diff", summary: "compare the schemas of source and destination and print a migration script", run: runDiff},
+	{name: "export", summary: "write source tables to a directory of JSONEachRow files", run: runExport},
+	{name: "import", summary: "load a directory written by export into the destination", run: runImport},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage()
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	usage()
+	return exitUsage
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: click-replicator <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Every command accepts -config <job file> and -json.")
+	fmt.Fprintln(os.Stderr, "Run 'click-replicator <command> -h' for the flags of a command.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Exit status:")
+	fmt.Fprintln(os.Stderr, "  0    success")
+	fmt.Fprintln(os.Stderr, "  1    the command failed")
+	fmt.Fprintln(os.Stderr, "  2    invalid flags, arguments or job file")
+	fmt.Fprintln(os.Stderr, "  3    verify or diff found differences")
+	fmt.Fprintln(os.Stderr, "  4    some tables failed")
+	fmt.Fprintln(os.Stderr, "  130  interrupted")
+}
+
+// signalContext returns a context cancelled by the first SIGINT or SIGTERM,
+// letting a replication finish the chunks in flight. A second signal
+// terminates the process immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func runPlan(args []string) int {
+	fs := newFlagSet("plan")
+	job := newJobFlags(fs)
+	schemaPolicy := schemaPolicyFlag(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	opts, err := schemaPolicy()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "plan:", err)
+		return exitUsage
+	}
+	replicator, err := job.replicator(opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "plan:", err)
+		return exitUsage
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	plan, err := replicator.Plan(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "plan:", err)
+		return exitFailure
+	}
+	if err := job.write(os.Stdout, plan); err != nil {
+		fmt.Fprintln(os.Stderr, "plan:", err)
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	clickreplicator "github.com/prasannakumar414/click-replicator"
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+func runReplicate(args []string) int {
+	fs := newFlagSet("replicate")
+	job := newJobFlags(fs)
+	run := newRunFlags(fs)
+	restart := fs.Bool("restart", false, "discard checkpoints of an interrupted run and start over")
+	var databases stringList
+	fs.Var(&databases, "databases", "replicate these databases instead of -source-database, each as name or source=destination (repeatable)")
+	allDatabases := fs.Bool("all-databases", false, "replicate every non-system database of the source")
+	schemaPolicy := schemaPolicyFlag(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	opts, err := schemaPolicy()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replicate:", err)
+		return exitUsage
+	}
+	runOpts, err := run.options()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replicate:", err)
+		return exitUsage
+	}
+	opts = append(opts, runOpts...)
+	if *restart {
+		opts = append(opts, clickreplicator.WithRestart())
+	}
+	if *allDatabases {
+		opts = append(opts, clickreplicator.WithAllDatabases())
+	}
+	for _, database := range databases {
+		pair := models.DatabaseMapping{Source: database}
+		if name, destination, ok := strings.Cut(database, "="); ok {
+			pair = models.DatabaseMapping{Source: name, Destination: destination}
+		}
+		opts = append(opts, clickreplicator.WithDatabases(pair))
+	}
+	replicator, err := job.replicator(opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replicate:", err)
+		return exitUsage
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	result, err := replicator.ReplicateDatabases(ctx)
+	if result != nil {
+		if writeErr := job.write(os.Stdout, result); writeErr != nil {
+			fmt.Fprintln(os.Stderr, "replicate:", writeErr)
+			return exitFailure
+		}
+	}
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		fmt.Fprintln(os.Stderr, "replicate: stopped after the chunks in flight; run again to resume")
+		return exitInterrupted
+	}
+	if result != nil && result.Count(models.StatusFailed) > 0 {
+		fmt.Fprintf(os.Stderr, "replicate: %d tables failed\n", result.Count(models.StatusFailed))
+		return exitPartial
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replicate:", err)
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func runVerify(args []string) int {
+	fs := newFlagSet("verify")
+	job := newJobFlags(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	replicator, err := job.replicator()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verify:", err)
+		return exitUsage
+	}
+	ctx, stop := signalContext()
+	defer stop()
+
+	report, err := replicator.Verify(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verify:", err)
+		return exitFailure
+	}
+	if err := job.write(os.Stdout, report); err != nil {
+		fmt.Fprintln(os.Stderr, "verify:", err)
+		return exitFailure
+	}
+	if !report.Match {
+		return exitMismatch
+	}
+	return exitOK
+}
@@ -0,0 +1,349 @@
+// Package config loads replication job files written in YAML or JSON.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/datasources/clickhouse"
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/engine"
+	"github.com/prasannakumar414/click-replicator/services/filter"
+	"github.com/prasannakumar414/click-replicator/services/mapping"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a job file.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// FormatOf returns the format implied by the extension of path.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %s; use a .yaml, .yml or .json extension", path)
+	}
+}
+
+// Load reads, interpolates, decodes and validates the job file at path.
+func Load(path string) (*models.JobConfig, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	job, err := Parse(data, format, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return job, nil
+}
+
+// Parse decodes and validates a job file. References to environment
+// variables in its values are resolved with lookup, see Interpolate; keys and
+// comments are left alone. Unknown keys are errors.
+func Parse(data []byte, format Format, lookup func(string) (string, bool)) (*models.JobConfig, error) {
+	var (
+		decoded []byte
+		err     error
+	)
+	switch format {
+	case FormatYAML:
+		decoded, err = interpolateYAML(data, lookup)
+	case FormatJSON:
+		decoded, err = interpolateJSON(data, lookup)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := &models.JobConfig{}
+	switch format {
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(decoded))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(job); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(decoded))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(job); err != nil {
+			return nil, err
+		}
+	}
+	if err := Validate(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// interpolateYAML resolves the references in the scalar values of a YAML
+// document and encodes it again. A plain scalar holding a number or a boolean
+// takes that type, so port: ${PORT} is a number, while every other value is a
+// string and the encoder quotes values that would otherwise change the
+// structure of the document.
+func interpolateYAML(data []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document.Kind == 0 {
+		return nil, nil
+	}
+	if err := interpolateNode(&document, lookup); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&document)
+}
+
+func interpolateNode(node *yaml.Node, lookup func(string) (string, bool)) error {
+	var errs []error
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			errs = append(errs, interpolateNode(child, lookup))
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, interpolateNode(node.Content[i], lookup))
+		}
+	case yaml.ScalarNode:
+		value, err := Interpolate(node.Value, lookup)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if value != node.Value {
+			node.Value = value
+			if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				// Only numbers and booleans are resolved again; anything else,
+				// null and ~ included, stays the string it was.
+				node.Tag = ""
+				switch node.ShortTag() {
+				case "!!int", "!!float", "!!bool":
+				default:
+					node.Tag = "!!str"
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// interpolateJSON resolves the references in the string values of a JSON
+// document and encodes it again. JSON has no unquoted references, so a
+// string holding nothing but one reference to a number or a boolean becomes
+// that number or boolean, as in "port": "${PORT}".
+func interpolateJSON(data []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("json: unexpected data after the job object")
+	}
+	document, err := interpolateValue(document, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
+
+func interpolateValue(value any, lookup func(string) (string, bool)) (any, error) {
+	var errs []error
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			var err error
+			value[key], err = interpolateValue(child, lookup)
+			errs = append(errs, err)
+		}
+	case []any:
+		for i, child := range value {
+			var err error
+			value[i], err = interpolateValue(child, lookup)
+			errs = append(errs, err)
+		}
+	case string:
+		interpolated, err := Interpolate(value, lookup)
+		if err != nil {
+			return value, err
+		}
+		if wholeReference.MatchString(value) {
+			switch {
+			case interpolated == "true" || interpolated == "false":
+				return interpolated == "true", nil
+			case jsonNumber.MatchString(interpolated):
+				return json.Number(interpolated), nil
+			}
+		}
+		return interpolated, nil
+	}
+	return value, errors.Join(errs...)
+}
+
+// variable matches $${...} escapes and ${NAME} or ${NAME:-default} references.
+var variable = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// wholeReference matches a value made of a single reference.
+var wholeReference = regexp.MustCompile(`^\$\{[^}]*\}$`)
+
+var jsonNumber = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?$`)
+
+// Interpolate replaces ${NAME} in a value with the value of the variable NAME
+// and ${NAME:-default} with default when NAME is unset or empty. $${ stands
+// for a literal ${. Unset variables without a default are errors, as are
+// malformed references.
+func Interpolate(text string, lookup func(string) (string, bool)) (string, error) {
+	var errs []error
+	result := variable.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		reference := match[2 : len(match)-1]
+		name, fallback, hasFallback := strings.Cut(reference, ":-")
+		if !variableName.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid variable reference %s", match))
+			return match
+		}
+		value, ok := lookup(name)
+		if ok && (value != "" || !hasFallback) {
+			return value
+		}
+		if hasFallback {
+			return fallback
+		}
+		errs = append(errs, fmt.Errorf("environment variable %s is not set", name))
+		return match
+	})
+	return result, errors.Join(errs...)
+}
+
+// Validate checks the values of job and reports every problem at once.
+func Validate(job *models.JobConfig) error {
+	var errs []error
+	fail := func(field string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	servers := []struct {
+		field  string
+		config models.ClickHouseConfig
+	}{{"source", job.Source}, {"destination", job.Destination}}
+	for _, server := range servers {
+		field := server.field
+		if err := clickhouse.ValidateConfig(server.config); err != nil {
+			fail(field, "%v", err)
+		} else if len(server.config.Addresses) == 0 && (server.config.Port < 1 || server.config.Port > 65535) {
+			fail(field+".port", "%d is not a valid port", server.config.Port)
+		}
+		if server.config.Database == "" && len(job.Databases) == 0 && !job.AllDatabases {
+			fail(field+".database", "is required unless databases or all_databases is set")
+		}
+	}
+	for i, database := range job.Databases {
+		if database.Source == "" {
+			fail(fmt.Sprintf("databases[%d].source", i), "is required")
+		}
+	}
+
+	if _, err := filter.New(job.Include, job.Exclude); err != nil {
+		fail("include/exclude", "%v", err)
+	}
+	if _, err := mapping.New(job.Mapping); err != nil {
+		fail("mapping", "%v", err)
+	}
+	if _, err := engine.New(job.Engines); err != nil {
+		fail("engines", "%v", err)
+	}
+
+	seen := make(map[string]bool, len(job.Tables))
+	for i, table := range job.Tables {
+		field := fmt.Sprintf("tables[%d]", i)
+		if table.Name == "" {
+			fail(field+".name", "is required")
+		} else if seen[table.Name] {
+			fail(field+".name", "table %s is configured twice", table.Name)
+		}
+		seen[table.Name] = true
+		if table.Lookback < 0 {
+			fail(field+".lookback", "must not be negative")
+		}
+		if table.Lookback > 0 && table.CursorColumn == "" {
+			fail(field+".lookback", "requires cursor_column")
+		}
+		switch table.Mode {
+		case "", models.LoadAppend, models.LoadTruncateReload, models.LoadFullRefreshSwap, models.LoadUpsert:
+			if table.CursorColumn != "" && !table.Mode.Incremental() {
+				fail(field+".mode", "%s copies every row and cannot be combined with cursor_column", table.Mode)
+			}
+		default:
+			fail(field+".mode", "unknown mode %q, expected %q, %q, %q or %q", table.Mode, models.LoadAppend, models.LoadTruncateReload, models.LoadFullRefreshSwap, models.LoadUpsert)
+		}
+	}
+
+	switch job.Transfer {
+	case "", models.TransferNative, models.TransferClient:
+	default:
+		fail("transfer", "unknown method %q, expected %q or %q", job.Transfer, models.TransferNative, models.TransferClient)
+	}
+	switch job.Order {
+	case models.OrderByName, models.OrderLargestFirst, models.OrderSmallestFirst:
+	default:
+		fail("order", "unknown order %q, expected %q or %q", job.Order, models.OrderLargestFirst, models.OrderSmallestFirst)
+	}
+	switch job.SchemaPolicy {
+	case "", models.SchemaWarn, models.SchemaApply, models.SchemaFail:
+	default:
+		fail("schema_policy", "unknown policy %q, expected %q, %q or %q", job.SchemaPolicy, models.SchemaWarn, models.SchemaApply, models.SchemaFail)
+	}
+	if job.Concurrency < 0 {
+		fail("concurrency", "must not be negative")
+	}
+	if job.MaxSourceQueries < 0 {
+		fail("max_source_queries", "must not be negative")
+	}
+	if job.Retries != nil && *job.Retries < 0 {
+		fail("retries", "must not be negative")
+	}
+	cluster := job.DestinationCluster
+	if cluster.DDLTimeout < 0 {
+		fail("destination_cluster.ddl_timeout", "must not be negative")
+	}
+	if cluster.Name == "" && (cluster.DDLTimeout != 0 || cluster.DistributedTables || cluster.DistributedSuffix != "") {
+		fail("destination_cluster", "requires name")
+	}
+	if cluster.DistributedSuffix != "" && !cluster.DistributedTables {
+		fail("destination_cluster.distributed_suffix", "requires distributed_tables")
+	}
+	if job.Checkpoints.File != "" && job.Checkpoints.Table != "" {
+		fail("checkpoints", "file and table are mutually exclusive")
+	}
+	if job.Checkpoints.Database != "" && job.Checkpoints.Table == "" {
+		fail("checkpoints.database", "requires checkpoints.table")
+	}
+	return errors.Join(errs...)
+}
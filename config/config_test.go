@@ -0,0 +1,402 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+// env returns a lookup reading variables from vars.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"HOST": "db1", "EMPTY": ""}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "no reference", text: "localhost", want: "localhost"},
+		{name: "reference", text: "${HOST}", want: "db1"},
+		{name: "embedded references", text: "${HOST}:${HOST}", want: "db1:db1"},
+		{name: "default for unset", text: "${MISSING:-db2}", want: "db2"},
+		{name: "default for empty", text: "${EMPTY:-db2}", want: "db2"},
+		{name: "set value wins over default", text: "${HOST:-db2}", want: "db1"},
+		{name: "empty default", text: "${MISSING:-}", want: ""},
+		{name: "empty without default", text: "${EMPTY}", want: ""},
+		{name: "escape", text: "$${HOST}", want: "${HOST}"},
+		{name: "lone dollar", text: "pa$$word", want: "pa$$word"},
+		{name: "unset", text: "${MISSING}", wantErr: true},
+		{name: "invalid name", text: "${1HOST}", wantErr: true},
+		{name: "empty name", text: "${}", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Interpolate(test.text, env(vars))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Interpolate(%q) = %q, want an error", test.text, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Interpolate(%q) error: %v", test.text, err)
+			}
+			if got != test.want {
+				t.Errorf("Interpolate(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+const servers = `
+source:
+  host: localhost
+  port: 9000
+  database: default
+destination:
+  host: localhost
+  port: 9000
+  database: destination
+`
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		vars    map[string]string
+		check   func(t *testing.T, job *models.JobConfig)
+		wantErr string
+	}{
+		{
+			name: "numbers take the type of their value",
+			text: servers + "concurrency: ${CONCURRENCY}\n",
+			vars: map[string]string{"CONCURRENCY": "4"},
+			check: func(t *testing.T, job *models.JobConfig) {
+				if job.Concurrency != 4 {
+					t.Errorf("concurrency = %d, want 4", job.Concurrency)
+				}
+			},
+		},
+		{
+			name: "values are not parsed as YAML",
+			text: servers + "staging_dir: ${DIR}\n",
+			vars: map[string]string{"DIR": "/tmp/a: b # c\nconcurrency: 9"},
+			check: func(t *testing.T, job *models.JobConfig) {
+				if job.StagingDir != "/tmp/a: b # c\nconcurrency: 9" {
+					t.Errorf("staging_dir = %q", job.StagingDir)
+				}
+				if job.Concurrency != 0 {
+					t.Errorf("concurrency = %d, want 0", job.Concurrency)
+				}
+			},
+		},
+		{
+			name: "quoted values stay strings",
+			text: servers + "staging_dir: '${DIR}'\n",
+			vars: map[string]string{"DIR": "1234"},
+			check: func(t *testing.T, job *models.JobConfig) {
+				if job.StagingDir != "1234" {
+					t.Errorf("staging_dir = %q, want 1234", job.StagingDir)
+				}
+			},
+		},
+		{
+			name: "null and tilde stay strings",
+			text: `
+source:
+  host: ${HOST}
+  port: 9000
+  database: ${DATABASE}
+  password: ${PW}
+destination:
+  host: localhost
+  port: 9000
+  database: destination
+  password: ${OTHER_PW}
+`,
+			vars: map[string]string{"HOST": "~", "DATABASE": "null", "PW": "null", "OTHER_PW": "~"},
+			check: func(t *testing.T, job *models.JobConfig) {
+				if job.Source.Host != "~" {
+					t.Errorf("source host = %q, want ~", job.Source.Host)
+				}
+				if job.Source.Database != "null" {
+					t.Errorf("source database = %q, want null", job.Source.Database)
+				}
+				if job.Source.Password != "null" {
+					t.Errorf("source password = %q, want null", job.Source.Password)
+				}
+				if job.Destination.Password != "~" {
+					t.Errorf("destination password = %q, want ~", job.Destination.Password)
+				}
+			},
+		},
+		{
+			name: "booleans take the type of their value",
+			text: servers + "strict: ${STRICT}\n",
+			vars: map[string]string{"STRICT": "true"},
+			check: func(t *testing.T, job *models.JobConfig) {
+				if !job.Strict {
+					t.Errorf("strict = false, want true")
+				}
+			},
+		},
+		{
+			name: "comments are not interpolated",
+			text: "# uses ${UNSET} on purpose\n" + servers + "strict: true # ${ALSO_UNSET}\n",
+			check: func(t *testing.T, job *models.JobConfig) {
+				if !job.Strict {
+					t.Errorf("strict = false, want true")
+				}
+			},
+		},
+		{
+			name: "escaped reference",
+			text: servers + "staging_dir: $${HOME}\n",
+			check: func(t *testing.T, job *models.JobConfig) {
+				if job.StagingDir != "${HOME}" {
+					t.Errorf("staging_dir = %q, want ${HOME}", job.StagingDir)
+				}
+			},
+		},
+		{
+			name:    "unset variable",
+			text:    servers + "staging_dir: ${UNSET}\n",
+			wantErr: "UNSET is not set",
+		},
+		{
+			name:    "unknown key",
+			text:    servers + "concurency: 4\n",
+			wantErr: "concurency",
+		},
+		{
+			name:    "invalid value",
+			text:    servers + "transfer: ftp\n",
+			wantErr: "transfer",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job, err := Parse([]byte(test.text), FormatYAML, env(test.vars))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Parse() error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			test.check(t, job)
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	text := `{
+  "source": {"host": "${HOST}", "port": "${PORT}", "database": "default", "password": "${PASSWORD}"},
+  "destination": {"host": "localhost", "port": 9000, "database": "destination"},
+  "strict": "${STRICT}",
+  "staging_dir": "/data/${PORT}"
+}`
+	vars := map[string]string{"HOST": "db1", "PORT": "9440", "PASSWORD": `se"cr\et`, "STRICT": "true"}
+	job, err := Parse([]byte(text), FormatJSON, env(vars))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if job.Source.Host != "db1" || job.Source.Port != 9440 {
+		t.Errorf("source = %s:%d, want db1:9440", job.Source.Host, job.Source.Port)
+	}
+	if job.Source.Password != `se"cr\et` {
+		t.Errorf("password = %q", job.Source.Password)
+	}
+	if !job.Strict {
+		t.Errorf("strict = false, want true")
+	}
+	if job.StagingDir != "/data/9440" {
+		t.Errorf("staging_dir = %q, want /data/9440", job.StagingDir)
+	}
+
+	if _, err := Parse([]byte(`{"source": {}} {}`), FormatJSON, env(nil)); err == nil {
+		t.Errorf("Parse() accepted data after the job object")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("example", func(t *testing.T) {
+		t.Setenv("SOURCE_HOST", "db1")
+		job, err := Load(filepath.Join("..", "examples", "job.yaml"))
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if job.Source.Host != "db1" || job.Destination.Host != "localhost" {
+			t.Errorf("hosts = %s, %s, want db1, localhost", job.Source.Host, job.Destination.Host)
+		}
+		if len(job.Tables) != 2 || job.Tables[0].Lookback != models.Duration(3600e9) {
+			t.Errorf("tables = %+v", job.Tables)
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "job.json")
+		text := `{"source": {"host": "a", "port": 9000, "database": "d"}, "destination": {"host": "b", "port": 9000, "database": "d"}}`
+		if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		job, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if job.Source.Host != "a" || job.Destination.Host != "b" {
+			t.Errorf("hosts = %s, %s, want a, b", job.Source.Host, job.Destination.Host)
+		}
+	})
+	t.Run("unknown extension", func(t *testing.T) {
+		if _, err := Load("job.toml"); err == nil {
+			t.Errorf("Load() accepted a .toml file")
+		}
+	})
+	t.Run("errors name the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "job.yaml")
+		if err := os.WriteFile(path, []byte("concurrency: -1\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if err == nil || !strings.HasPrefix(err.Error(), path+": ") {
+			t.Errorf("Load() error = %v, want one starting with the path", err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *models.JobConfig {
+		return &models.JobConfig{
+			Source:      models.ClickHouseConfig{Host: "a", Port: 9000, Database: "d"},
+			Destination: models.ClickHouseConfig{Host: "b", Port: 9000, Database: "d"},
+		}
+	}
+	negative := -1
+	tests := []struct {
+		name   string
+		modify func(job *models.JobConfig)
+		want   []string
+	}{
+		{name: "valid", modify: func(job *models.JobConfig) {}},
+		{
+			name:   "missing host and database",
+			modify: func(job *models.JobConfig) { job.Source = models.ClickHouseConfig{Port: 9000} },
+			want:   []string{"source: host or addresses is required", "source.database: is required"},
+		},
+		{
+			name:   "invalid port",
+			modify: func(job *models.JobConfig) { job.Destination.Port = 70000 },
+			want:   []string{"destination.port: 70000 is not a valid port"},
+		},
+		{
+			name: "databases instead of a database",
+			modify: func(job *models.JobConfig) {
+				job.Source.Database, job.Destination.Database = "", ""
+				job.Databases = []models.DatabaseMapping{{Source: "a"}}
+			},
+		},
+		{
+			name:   "database without source",
+			modify: func(job *models.JobConfig) { job.Databases = []models.DatabaseMapping{{Destination: "a"}} },
+			want:   []string{"databases[0].source: is required"},
+		},
+		{
+			name:   "invalid filter",
+			modify: func(job *models.JobConfig) { job.Exclude = []string{"re:("} },
+			want:   []string{"include/exclude:"},
+		},
+		{
+			name:   "invalid mapping",
+			modify: func(job *models.JobConfig) { job.Mapping.Template = "stg" },
+			want:   []string{"mapping:"},
+		},
+		{
+			name:   "unknown engine preset",
+			modify: func(job *models.JobConfig) { job.Engines.Presets = []string{"nope"} },
+			want:   []string{"engines:"},
+		},
+		{
+			name: "table errors",
+			modify: func(job *models.JobConfig) {
+				job.Tables = []models.TableConfig{
+					{Name: "events", Lookback: models.Duration(1e9)},
+					{Name: "events", Mode: models.LoadTruncateReload, CursorColumn: "ts"},
+					{Mode: "merge"},
+				}
+			},
+			want: []string{
+				"tables[0].lookback: requires cursor_column",
+				"tables[1].name: table events is configured twice",
+				"tables[1].mode: truncate-and-reload copies every row",
+				"tables[2].name: is required",
+				`tables[2].mode: unknown mode "merge"`,
+			},
+		},
+		{
+			name: "unknown enums",
+			modify: func(job *models.JobConfig) {
+				job.Transfer, job.Order, job.SchemaPolicy = "ftp", "random", "ignore"
+			},
+			want: []string{`transfer: unknown method "ftp"`, `order: unknown order "random"`, `schema_policy: unknown policy "ignore"`},
+		},
+		{
+			name: "negative numbers",
+			modify: func(job *models.JobConfig) {
+				job.Concurrency, job.MaxSourceQueries, job.Retries = -1, -1, &negative
+			},
+			want: []string{"concurrency: must not be negative", "max_source_queries: must not be negative", "retries: must not be negative"},
+		},
+		{
+			name: "cluster settings without a name",
+			modify: func(job *models.JobConfig) {
+				job.DestinationCluster = models.ClusterConfig{DistributedSuffix: "_all"}
+			},
+			want: []string{"destination_cluster: requires name", "destination_cluster.distributed_suffix: requires distributed_tables"},
+		},
+		{
+			name: "checkpoint file and table",
+			modify: func(job *models.JobConfig) {
+				job.Checkpoints = models.CheckpointConfig{File: "a.json", Table: "checkpoints"}
+			},
+			want: []string{"checkpoints: file and table are mutually exclusive"},
+		},
+		{
+			name:   "checkpoint database without table",
+			modify: func(job *models.JobConfig) { job.Checkpoints.Database = "meta" },
+			want:   []string{"checkpoints.database: requires checkpoints.table"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := valid()
+			test.modify(job)
+			err := Validate(job)
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() succeeded, want errors %q", test.want)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
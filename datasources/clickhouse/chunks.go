@@ -0,0 +1,288 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+// chunkSampleSize bounds the number of sorting key values sampled to place chunk boundaries.
+const chunkSampleSize = 65536
+
+// GetSortingKey returns the sorting_key of the table from system.tables.
+func (cs ClickhouseService) GetSortingKey(ctx context.Context, tableName string) (string, error) {
+	query := fmt.Sprintf("SELECT sorting_key FROM system.tables WHERE database = '%s' AND name = '%s'", cs.database, tableName)
+
+	var sortingKey string
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&sortingKey); err != nil {
+		return "", err
+	}
+	return sortingKey, nil
+}
+
+// GetRowCountWhere counts the rows matching condition, a boolean expression.
+func (cs ClickhouseService) GetRowCountWhere(ctx context.Context, tableName string, condition string) (uint64, error) {
+	query := fmt.Sprintf("SELECT count() FROM %s.%s WHERE %s", cs.database, tableName, condition)
+
+	var count uint64
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// PlanChunks splits the rows of the table matching scope (a boolean
+// expression, or empty for the whole table) into ranges of the first sorting
+// key column holding roughly rowsPerChunk rows each. Boundaries are quantiles
+// of a seeded sample of the key, so planning reads the key column only once.
+// A range holding more than maxChunkGrowth times rowsPerChunk rows, as a key
+// with few distinct values yields, is split again by the next sorting key
+// column, and once the key is exhausted by a hash of every column. Tables
+// with fewer rows than rowsPerChunk yield a single chunk covering scope.
+func (cs ClickhouseService) PlanChunks(ctx context.Context, tableName string, scope string, rowsPerChunk uint64) ([]models.Chunk, error) {
+	whole := models.Chunk{ID: "all", Condition: scope}
+	if rowsPerChunk == 0 {
+		return []models.Chunk{whole}, nil
+	}
+	sortingKey, err := cs.GetSortingKey(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	return cs.planChunks(ctx, tableName, whole, splitExpressions(sortingKey), rowsPerChunk)
+}
+
+// maxChunkGrowth is how many times rowsPerChunk a planned chunk may hold
+// before it is split further.
+const maxChunkGrowth = 2
+
+// planChunks splits parent into ranges of the first of keys, and splits the
+// ranges that are still too large by the remaining keys.
+func (cs ClickhouseService) planChunks(ctx context.Context, tableName string, parent models.Chunk, keys []string, rowsPerChunk uint64) ([]models.Chunk, error) {
+	if len(keys) == 0 {
+		return cs.planHashChunks(ctx, tableName, parent, rowsPerChunk)
+	}
+	key := keys[0]
+
+	where := ""
+	if parent.Condition != "" {
+		where = "WHERE " + parent.Condition
+	}
+	query := fmt.Sprintf("SELECT count(), any(toTypeName(%s)) FROM %s.%s %s", key, cs.database, tableName, where)
+	var (
+		rows    uint64
+		keyType string
+	)
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&rows, &keyType); err != nil {
+		return nil, err
+	}
+	if rows <= rowsPerChunk {
+		return []models.Chunk{parent}, nil
+	}
+
+	count := (rows + rowsPerChunk - 1) / rowsPerChunk
+	query = fmt.Sprintf(`SELECT arrayDistinct(arrayMap(i -> toString(s[toUInt64(floor(i * length(s) / %d)) + 1]), range(1, %d)))
+FROM (SELECT arraySort(groupArraySample(%d, 1)(%s)) AS s FROM %s.%s %s)`, count, count, chunkSampleSize, key, cs.database, tableName, where)
+	var boundaries []string
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&boundaries); err != nil {
+		return nil, err
+	}
+	chunks := nestChunks(parent, buildChunks(key, keyType, parent.Condition, boundaries))
+	sizes, err := cs.countChunks(ctx, tableName, chunks)
+	if err != nil {
+		return nil, err
+	}
+	var planned []models.Chunk
+	for i, chunk := range chunks {
+		if sizes[i] <= rowsPerChunk*maxChunkGrowth {
+			planned = append(planned, chunk)
+			continue
+		}
+		split, err := cs.planChunks(ctx, tableName, chunk, keys[1:], rowsPerChunk)
+		if err != nil {
+			return nil, err
+		}
+		planned = append(planned, split...)
+	}
+	return planned, nil
+}
+
+// planHashChunks splits parent into buckets of a hash of every column, for
+// rows the sorting key cannot tell apart. It is left whole unless it holds
+// more than maxChunkGrowth times rowsPerChunk rows.
+func (cs ClickhouseService) planHashChunks(ctx context.Context, tableName string, parent models.Chunk, rowsPerChunk uint64) ([]models.Chunk, error) {
+	query := fmt.Sprintf("SELECT count() FROM %s.%s", cs.database, tableName)
+	if parent.Condition != "" {
+		query += " WHERE " + parent.Condition
+	}
+	var rows uint64
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&rows); err != nil {
+		return nil, err
+	}
+	if rows <= rowsPerChunk*maxChunkGrowth {
+		return []models.Chunk{parent}, nil
+	}
+	columns, err := cs.GetColumns(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteIdentifier(column.Name)
+	}
+	hash := "cityHash64(" + strings.Join(names, ", ") + ")"
+	return hashChunks(parent, hash, (rows+rowsPerChunk-1)/rowsPerChunk), nil
+}
+
+// countChunks counts the rows of every chunk in a single scan.
+func (cs ClickhouseService) countChunks(ctx context.Context, tableName string, chunks []models.Chunk) ([]uint64, error) {
+	counts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		counts[i] = "countIf(" + chunk.Condition + ")"
+	}
+	query := fmt.Sprintf("SELECT [%s] FROM %s.%s", strings.Join(counts, ", "), cs.database, tableName)
+	var sizes []uint64
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&sizes); err != nil {
+		return nil, err
+	}
+	if len(sizes) != len(chunks) {
+		return nil, fmt.Errorf("counted %d chunks, planned %d", len(sizes), len(chunks))
+	}
+	return sizes, nil
+}
+
+// GetRowJsonsForChunk returns the rows of a chunk in sorting key order, each
+// rendered by the server in the given row format such as JSONEachRow.
+func (cs ClickhouseService) GetRowJsonsForChunk(ctx context.Context, tableName string, format string, chunk models.Chunk) ([]string, error) {
+	sortingKey, err := cs.GetSortingKey(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT formatRowNoNewline('%s', *) FROM %s.%s", format, cs.database, tableName)
+	if chunk.Condition != "" {
+		query += " WHERE " + chunk.Condition
+	}
+	if sortingKey != "" {
+		query += " ORDER BY " + sortingKey
+	}
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jsonData []string
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		jsonData = append(jsonData, data)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jsonData, nil
+}
+
+// buildChunks turns sorted boundaries into half-open key ranges. The first
+// and last ranges are unbounded so every row, including NULL keys, belongs to
+// exactly one chunk.
+func buildChunks(key string, keyType string, scope string, boundaries []string) []models.Chunk {
+	chunks := make([]models.Chunk, 0, len(boundaries)+1)
+	for i := 0; i <= len(boundaries); i++ {
+		var (
+			conditions []string
+			chunk      models.Chunk
+		)
+		if scope != "" {
+			conditions = append(conditions, "("+scope+")")
+		}
+		if i > 0 {
+			chunk.Lower = boundaries[i-1]
+			conditions = append(conditions, fmt.Sprintf("%s >= %s", key, castLiteral(chunk.Lower, keyType)))
+		}
+		if i < len(boundaries) {
+			chunk.Upper = boundaries[i]
+			upper := fmt.Sprintf("%s < %s", key, castLiteral(chunk.Upper, keyType))
+			if i == 0 && strings.HasPrefix(keyType, "Nullable(") {
+				upper = fmt.Sprintf("(%s OR %s IS NULL)", upper, key)
+			}
+			conditions = append(conditions, upper)
+		}
+		chunk.ID = fmt.Sprintf("range:%s..%s", chunk.Lower, chunk.Upper)
+		chunk.Condition = strings.Join(conditions, " AND ")
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// nestChunks prefixes the IDs of chunks split from parent with its ID, so
+// they stay unique across the table. Chunks of the whole table keep theirs.
+func nestChunks(parent models.Chunk, chunks []models.Chunk) []models.Chunk {
+	if parent.ID == "all" {
+		return chunks
+	}
+	for i := range chunks {
+		chunks[i].ID = parent.ID + "/" + chunks[i].ID
+	}
+	return chunks
+}
+
+// hashChunks splits parent into buckets of rows by hash, an expression
+// spreading the rows evenly.
+func hashChunks(parent models.Chunk, hash string, buckets uint64) []models.Chunk {
+	chunks := make([]models.Chunk, buckets)
+	for i := range chunks {
+		condition := fmt.Sprintf("%s %% %d = %d", hash, buckets, i)
+		if parent.Condition != "" {
+			condition = "(" + parent.Condition + ") AND " + condition
+		}
+		chunks[i] = models.Chunk{ID: fmt.Sprintf("hash:%d-of-%d", i, buckets), Condition: condition}
+	}
+	return nestChunks(parent, chunks)
+}
+
+// splitExpressions splits a comma separated list of expressions, ignoring
+// commas nested in parentheses or quotes.
+func splitExpressions(list string) []string {
+	var (
+		expressions []string
+		depth       int
+		quote       rune
+		start       int
+	)
+	for i, r := range list {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '`' || r == '"':
+			quote = r
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			depth--
+		case r == ',' && depth == 0:
+			expressions = append(expressions, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(list[start:]); last != "" {
+		expressions = append(expressions, last)
+	}
+	return expressions
+}
+
+func castLiteral(value string, columnType string) string {
+	return fmt.Sprintf("CAST(%s, %s)", quoteString(value), quoteString(columnType))
+}
+
+func quoteString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
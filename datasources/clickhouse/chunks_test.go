@@ -0,0 +1,124 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+func TestSplitExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{name: "empty", list: "", want: nil},
+		{name: "single", list: "id", want: []string{"id"}},
+		{name: "several", list: "tenant, id,created_at", want: []string{"tenant", "id", "created_at"}},
+		{name: "nested parentheses", list: "toStartOfDay(ts), cityHash64(a, b)", want: []string{"toStartOfDay(ts)", "cityHash64(a, b)"}},
+		{name: "brackets", list: "arrayElement([1, 2], 1), id", want: []string{"arrayElement([1, 2], 1)", "id"}},
+		{name: "quoted comma", list: "concat(a, ','), `b,c`", want: []string{"concat(a, ',')", "`b,c`"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := splitExpressions(test.list); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("splitExpressions(%q) = %q, want %q", test.list, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildChunks(t *testing.T) {
+	tests := []struct {
+		name       string
+		keyType    string
+		scope      string
+		boundaries []string
+		want       []models.Chunk
+	}{
+		{
+			name:    "no boundaries",
+			keyType: "UInt64",
+			want:    []models.Chunk{{ID: "range:..", Condition: ""}},
+		},
+		{
+			name:       "two boundaries",
+			keyType:    "UInt64",
+			boundaries: []string{"10", "20"},
+			want: []models.Chunk{
+				{ID: "range:..10", Condition: "id < CAST('10', 'UInt64')", Upper: "10"},
+				{ID: "range:10..20", Condition: "id >= CAST('10', 'UInt64') AND id < CAST('20', 'UInt64')", Lower: "10", Upper: "20"},
+				{ID: "range:20..", Condition: "id >= CAST('20', 'UInt64')", Lower: "20"},
+			},
+		},
+		{
+			name:       "scope",
+			keyType:    "UInt64",
+			scope:      "_partition_id = '1'",
+			boundaries: []string{"10"},
+			want: []models.Chunk{
+				{ID: "range:..10", Condition: "(_partition_id = '1') AND id < CAST('10', 'UInt64')", Upper: "10"},
+				{ID: "range:10..", Condition: "(_partition_id = '1') AND id >= CAST('10', 'UInt64')", Lower: "10"},
+			},
+		},
+		{
+			name:       "nullable key",
+			keyType:    "Nullable(UInt64)",
+			boundaries: []string{"10"},
+			want: []models.Chunk{
+				{ID: "range:..10", Condition: "(id < CAST('10', 'Nullable(UInt64)') OR id IS NULL)", Upper: "10"},
+				{ID: "range:10..", Condition: "id >= CAST('10', 'Nullable(UInt64)')", Lower: "10"},
+			},
+		},
+		{
+			name:       "quoted boundary",
+			keyType:    "String",
+			boundaries: []string{`it's`},
+			want: []models.Chunk{
+				{ID: "range:..it's", Condition: `id < CAST('it\'s', 'String')`, Upper: "it's"},
+				{ID: "range:it's..", Condition: `id >= CAST('it\'s', 'String')`, Lower: "it's"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := buildChunks("id", test.keyType, test.scope, test.boundaries)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("buildChunks() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHashChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent models.Chunk
+		want   []models.Chunk
+	}{
+		{
+			name:   "whole table",
+			parent: models.Chunk{ID: "all"},
+			want: []models.Chunk{
+				{ID: "hash:0-of-2", Condition: "h % 2 = 0"},
+				{ID: "hash:1-of-2", Condition: "h % 2 = 1"},
+			},
+		},
+		{
+			name:   "range",
+			parent: models.Chunk{ID: "range:10..20", Condition: "id >= 10"},
+			want: []models.Chunk{
+				{ID: "range:10..20/hash:0-of-2", Condition: "(id >= 10) AND h % 2 = 0"},
+				{ID: "range:10..20/hash:1-of-2", Condition: "(id >= 10) AND h % 2 = 1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := hashChunks(test.parent, "h", 2); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("hashChunks() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/prasannakumar414/click-replicator/models"
 	"github.com/prasannakumar414/click-replicator/tools"
 	"github.com/prasannakumar414/click-replicator/utils"
 
@@ -17,6 +20,9 @@ type ClickhouseService struct {
 	Conn     driver.Conn
 	logger   *zap.Logger
 	database string
+	// cluster, when set, is the cluster DDL runs on; see OnCluster.
+	cluster    string
+	ddlTimeout time.Duration
 }
 
 func NewClickhouseService(conn driver.Conn, logger *zap.Logger, database string) *ClickhouseService {
@@ -34,7 +40,7 @@ func (service *ClickhouseService) GetAllTables(ctx context.Context) ([]string, e
 
 	rows, err := service.Conn.Query(ctx, query)
 	if err != nil {
-		fmt.Println("Error executing query:", err)
+		service.logger.Error("Error executing query", zap.Error(err))
 		return nil, err
 	}
 	defer rows.Close()
@@ -58,7 +64,7 @@ func (service *ClickhouseService) IsTableExists(ctx context.Context, tableName s
 
 	var count uint64
 	if err := service.Conn.QueryRow(ctx, query).Scan(&count); err != nil {
-		fmt.Println("Error executing query:", err)
+		service.logger.Error("Error executing query", zap.Error(err))
 		return false, err
 	}
 
@@ -70,7 +76,7 @@ func (service *ClickhouseService) GetRowCount(ctx context.Context, tableName str
 
 	var count uint64
 	if err := service.Conn.QueryRow(ctx, query).Scan(&count); err != nil {
-		fmt.Println("Error executing query:", err)
+		service.logger.Error("Error executing query", zap.Error(err))
 		return 0, err
 	}
 
@@ -82,7 +88,7 @@ func (service *ClickhouseService) GetRowJsons(ctx context.Context, tableName str
 
 	rows, err := service.Conn.Query(ctx, query)
 	if err != nil {
-		fmt.Println("Error executing query:", err)
+		service.logger.Error("Error executing query", zap.Error(err))
 		return nil, err
 	}
 	defer rows.Close()
@@ -103,12 +109,14 @@ func (service *ClickhouseService) GetRowJsons(ctx context.Context, tableName str
 	return jsonData, nil
 }
 
+// Deprecated: LIMIT/OFFSET pages are neither deterministic nor cheap on large
+// tables. Plan chunks with PlanChunks and read them with GetRowJsonsForChunk.
 func (service *ClickhouseService) GetRowJsonsWithLimit(ctx context.Context, tableName string, format string, limit int, offset int) ([]string, error) {
 	query := fmt.Sprintf("SELECT * FROM %s.%s limit %d offset %d FORMAT %s", service.database, tableName, limit, offset, format)
-	fmt.Println("query: ", query)
+	service.logger.Debug("Executing query", zap.String("query", query))
 	rows, err := service.Conn.Query(ctx, query)
 	if err != nil {
-		fmt.Println("Error executing query:", err)
+		service.logger.Error("Error executing query", zap.Error(err))
 		return nil, err
 	}
 	defer rows.Close()
@@ -116,11 +124,9 @@ func (service *ClickhouseService) GetRowJsonsWithLimit(ctx context.Context, tabl
 	var jsonData []string
 	for rows.Next() {
 		var data []any = make([]any, len(columnNames))
-		fmt.Println("data: ", data)
 		if err := rows.Scan(data...); err != nil {
 			return nil, err
 		}
-		fmt.Println("data: ", data)
 		var json map[string]any = make(map[string]any)
 		for i, columnName := range columnNames {
 			json[columnName] = data[i]	
@@ -139,13 +145,13 @@ func (service *ClickhouseService) GetRowJsonsWithLimit(ctx context.Context, tabl
 func (service *ClickhouseService) CreateClickhouseTable(ctx context.Context, tableName string, rowJson string) error {
 	columns, _, err := service.GetAllColumnNameAndTypes(tableName, rowJson)
 	if err != nil {
-		fmt.Println("Error getting column names and types:", err)
+		service.logger.Error("Error getting column names and types", zap.Error(err))
 	}
 	// Construct the CREATE TABLE query
 	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s) ENGINE = MergeTree() ORDER BY tuple()", service.database, tableName, columns)
 
 	if err := service.Conn.Exec(ctx, query); err != nil {
-		fmt.Println("Error creating table:", err)
+		service.logger.Error("Error creating table", zap.Error(err))
 		return err
 	}
 
@@ -156,12 +162,12 @@ func (cs ClickhouseService) GetAllColumnNameAndTypes(table string, rowJson strin
 	jsonData := []byte(rowJson)
 	var dataMap map[string]interface{}
 	if err := json.Unmarshal(jsonData, &dataMap); err != nil {
-		fmt.Println("Error unmarshalling JSON:", err)
+		cs.logger.Error("Error unmarshalling JSON", zap.Error(err))
 		return "", nil, err
 	}
 	flattenedJson, err := tools.Flatten(dataMap, "", tools.UnderscoreStyle)
 	if err != nil {
-		fmt.Println("Error flattening JSON:", err)
+		cs.logger.Error("Error flattening JSON", zap.Error(err))
 		return "", nil, err
 	}
 
@@ -180,7 +186,7 @@ func (cs ClickhouseService) GetAllColumnNameAndTypes(table string, rowJson strin
 func (cs ClickhouseService) AlterTableColumnType(ctx context.Context, tableName string, columnName string, newType string) error {
 	query := fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s Nullable(%s)", tableName, columnName, newType)
 	if err := cs.Conn.Exec(ctx, query); err != nil {
-		fmt.Println("Error altering column type:", err)
+		cs.logger.Error("Error altering column type", zap.Error(err))
 		return err
 	}
 	return nil
@@ -190,7 +196,7 @@ func (cs ClickhouseService) AddColumns(ctx context.Context, tableName string, co
 	for _, column := range columns {
 		query := fmt.Sprintf("ALTER TABLE %s.%s ADD COLUMN %s Nullable(String)", cs.database, tableName, column)
 		if err := cs.Conn.Exec(ctx, query); err != nil {
-			fmt.Println("Error adding column:", err)
+			cs.logger.Error("Error adding column", zap.Error(err))
 			return err
 		}
 	}
@@ -203,7 +209,7 @@ func (cs ClickhouseService) GetColumnNames(ctx context.Context, tableName string
 
 	rows, err := cs.Conn.Query(ctx, query)
 	if err != nil {
-		fmt.Println("Error executing query:", err)
+		cs.logger.Error("Error executing query", zap.Error(err))
 		return nil, err
 	}
 	defer rows.Close()
@@ -249,21 +255,313 @@ func (cs ClickhouseService) CreateTableFromJSONData(ctx context.Context, tableNa
 }
 
 func (cs ClickhouseService) CreateDatabase(ctx context.Context) error {
-	query := "CREATE DATABASE IF NOT EXISTS %s;"
-	query = fmt.Sprintf(query, cs.database)
-	err := cs.Conn.Exec(ctx, query)
+	err := cs.exec(ctx, cs.CreateDatabaseQuery())
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (cs ClickhouseService) CreateDatabaseQuery() string {
+	return cs.ClusterQuery(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", cs.database))
+}
+
+// DropTableQuery returns the statement dropping the table if it exists.
+func (cs ClickhouseService) DropTableQuery(tableName string) string {
+	return cs.ClusterQuery(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", cs.database, tableName))
+}
+
+// ExchangeTablesQuery returns the statement atomically swapping the names of
+// two tables. It needs an Atomic database.
+func (cs ClickhouseService) ExchangeTablesQuery(tableName string, otherTableName string) string {
+	return cs.ClusterQuery(fmt.Sprintf("EXCHANGE TABLES %s.%s AND %s.%s", cs.database, tableName, cs.database, otherTableName))
+}
+
 func (cs ClickhouseService) OptimizeTable(ctx context.Context, tableName string) error {
 	query := "OPTIMIZE TABLE %s.%s"
 	query = fmt.Sprintf(query, cs.database, tableName)
-	err := cs.Conn.Exec(ctx, query)
+	err := cs.exec(ctx, query)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (cs ClickhouseService) Database() string {
+	return cs.database
+}
+
+// GetCreateTableQuery returns the create_table_query of the table as stored in system.tables.
+func (cs ClickhouseService) GetCreateTableQuery(ctx context.Context, tableName string) (string, error) {
+	query := fmt.Sprintf("SELECT create_table_query FROM system.tables WHERE database = '%s' AND name = '%s'", cs.database, tableName)
+
+	var createTableQuery string
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&createTableQuery); err != nil {
+		return "", err
+	}
+	return createTableQuery, nil
+}
+
+// GetColumns returns the columns of the table ordered by position.
+func (cs ClickhouseService) GetColumns(ctx context.Context, tableName string) ([]models.Column, error) {
+	query := fmt.Sprintf("SELECT name, type, position, default_kind, default_expression, comment, compression_codec FROM system.columns WHERE database = '%s' AND table = '%s' ORDER BY position", cs.database, tableName)
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []models.Column
+	for rows.Next() {
+		var column models.Column
+		if err := rows.Scan(&column.Name, &column.Type, &column.Position, &column.DefaultKind, &column.DefaultExpression, &column.Comment, &column.CompressionCodec); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// ExecuteDDL runs a statement, on every host of the cluster if the service has one.
+func (cs ClickhouseService) ExecuteDDL(ctx context.Context, query string) error {
+	return cs.exec(ctx, query)
+}
+
+// GetMaxValue returns max(column) of the table rendered with toString.
+func (cs ClickhouseService) GetMaxValue(ctx context.Context, tableName string, column string) (string, error) {
+	query := fmt.Sprintf("SELECT toString(max(%s)) FROM %s.%s", quoteIdentifier(column), cs.database, tableName)
+
+	var value string
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// DeleteRows removes the rows matching condition and waits for the mutation to finish.
+func (cs ClickhouseService) DeleteRows(ctx context.Context, tableName string, condition string) error {
+	query := fmt.Sprintf("ALTER TABLE %s.%s DELETE WHERE %s SETTINGS mutations_sync = 2", cs.database, tableName, condition)
+	return cs.exec(ctx, query)
+}
+
+func (cs ClickhouseService) TruncateTable(ctx context.Context, tableName string) error {
+	query := fmt.Sprintf("TRUNCATE TABLE %s.%s", cs.database, tableName)
+	return cs.exec(ctx, query)
+}
+
+// GetTableSizes returns the bytes on disk of the active parts of every table in the database.
+func (cs ClickhouseService) GetTableSizes(ctx context.Context) (map[string]uint64, error) {
+	query := fmt.Sprintf("SELECT table, sum(bytes_on_disk) FROM system.parts WHERE database = '%s' AND active GROUP BY table", cs.database)
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(map[string]uint64)
+	for rows.Next() {
+		var (
+			table string
+			bytes uint64
+		)
+		if err := rows.Scan(&table, &bytes); err != nil {
+			return nil, err
+		}
+		sizes[table] = bytes
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// GetPartitions lists the partitions of the table with the rows and bytes of their active parts.
+func (cs ClickhouseService) GetPartitions(ctx context.Context, tableName string) ([]models.Partition, error) {
+	query := fmt.Sprintf("SELECT partition_id, any(partition), sum(rows), sum(bytes_on_disk) FROM system.parts WHERE database = '%s' AND table = '%s' AND active GROUP BY partition_id ORDER BY partition_id", cs.database, tableName)
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []models.Partition
+	for rows.Next() {
+		var partition models.Partition
+		if err := rows.Scan(&partition.ID, &partition.Name, &partition.Rows, &partition.Bytes); err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, partition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return partitions, nil
+}
+
+func (cs ClickhouseService) DropPartition(ctx context.Context, tableName string, partitionID string) error {
+	query := fmt.Sprintf("ALTER TABLE %s.%s DROP PARTITION ID '%s'", cs.database, tableName, partitionID)
+	return cs.exec(ctx, query)
+}
+
+// GetPartitionChecksums returns the row count and groupBitXor(cityHash64(*)) of every partition of the table.
+func (cs ClickhouseService) GetPartitionChecksums(ctx context.Context, tableName string) (map[string]models.Checksum, error) {
+	query := fmt.Sprintf("SELECT _partition_id, count(), groupBitXor(cityHash64(*)) FROM %s.%s GROUP BY _partition_id", cs.database, tableName)
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := make(map[string]models.Checksum)
+	for rows.Next() {
+		var (
+			partitionID string
+			checksum    models.Checksum
+		)
+		if err := rows.Scan(&partitionID, &checksum.Rows, &checksum.Hash); err != nil {
+			return nil, err
+		}
+		checksums[partitionID] = checksum
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+func (cs ClickhouseService) GetTableEngine(ctx context.Context, tableName string) (string, error) {
+	query := fmt.Sprintf("SELECT engine FROM system.tables WHERE database = '%s' AND name = '%s'", cs.database, tableName)
+
+	var engine string
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&engine); err != nil {
+		return "", err
+	}
+	return engine, nil
+}
+
+// GetClusters returns the names of the clusters the server knows, sorted by name.
+func (cs ClickhouseService) GetClusters(ctx context.Context) ([]string, error) {
+	rows, err := cs.Conn.Query(ctx, "SELECT DISTINCT cluster FROM system.clusters ORDER BY cluster")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clusters []string
+	for rows.Next() {
+		var cluster string
+		if err := rows.Scan(&cluster); err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+// GetObjects lists the tables, views and dictionaries of the database with
+// their engine and the objects of the same database depending on them.
+func (cs ClickhouseService) GetObjects(ctx context.Context) ([]models.DatabaseObject, error) {
+	query := fmt.Sprintf("SELECT name, engine, dependencies_database, dependencies_table FROM system.tables WHERE database = '%s' ORDER BY name", cs.database)
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []models.DatabaseObject
+	for rows.Next() {
+		var (
+			object    models.DatabaseObject
+			databases []string
+			tables    []string
+		)
+		if err := rows.Scan(&object.Name, &object.Engine, &databases, &tables); err != nil {
+			return nil, err
+		}
+		for i, table := range tables {
+			if i < len(databases) && databases[i] == cs.database {
+				object.Dependents = append(object.Dependents, table)
+			}
+		}
+		objects = append(objects, object)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ttlClause finds the table TTL in engine_full, which lists the clauses of
+// the engine in the order PARTITION BY, ORDER BY, ..., TTL, SETTINGS.
+var ttlClause = regexp.MustCompile(`(?s)\sTTL\s(.+?)(?:\sSETTINGS\s.*)?$`)
+
+// GetTableSchema returns the engine, keys and TTL of the table.
+func (cs ClickhouseService) GetTableSchema(ctx context.Context, tableName string) (models.TableSchema, error) {
+	query := fmt.Sprintf("SELECT engine, engine_full, partition_key, sorting_key, primary_key FROM system.tables WHERE database = '%s' AND name = '%s'", cs.database, tableName)
+
+	var (
+		schema     models.TableSchema
+		engineFull string
+	)
+	if err := cs.Conn.QueryRow(ctx, query).Scan(&schema.Engine, &engineFull, &schema.PartitionKey, &schema.SortingKey, &schema.PrimaryKey); err != nil {
+		return schema, err
+	}
+	if match := ttlClause.FindStringSubmatch(engineFull); match != nil {
+		schema.TTL = strings.TrimSpace(match[1])
+	}
+	return schema, nil
+}
+
+// systemDatabases are left out by GetAllDatabases.
+var systemDatabases = []string{"system", "INFORMATION_SCHEMA", "information_schema", "_temporary_and_external_tables"}
+
+// GetAllDatabases returns the names of every database on the server except
+// the system ones, sorted by name.
+func (cs ClickhouseService) GetAllDatabases(ctx context.Context) ([]string, error) {
+	excluded := make([]string, len(systemDatabases))
+	for i, database := range systemDatabases {
+		excluded[i] = quoteString(database)
+	}
+	query := fmt.Sprintf("SELECT name FROM system.databases WHERE name NOT IN (%s) ORDER BY name", strings.Join(excluded, ", "))
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var databases []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		databases = append(databases, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return databases, nil
+}
+
+// ForDatabase returns a service for another database of the same server,
+// sharing the connection and the cluster.
+func (cs ClickhouseService) ForDatabase(database string) *ClickhouseService {
+	cs.database = database
+	return &cs
+}
@@ -0,0 +1,46 @@
+package clickhouse
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+// ClientArgs returns the clickhouse-client arguments connecting to the server
+// of config: the first of its addresses or its host and port, over TLS when
+// it has a TLS config, and without compression when it asks for none. The
+// user and password are passed in the environment, see secret.ClientEnv.
+// clickhouse-client only speaks the native protocol and reads certificates
+// from its own config file, so HTTP and TLS certificate files are errors.
+func ClientArgs(config models.ClickHouseConfig) ([]string, error) {
+	if config.Protocol == models.ProtocolHTTP {
+		return nil, fmt.Errorf("clickhouse-client does not speak the %s protocol", config.Protocol)
+	}
+	host, port := config.Host, strconv.Itoa(config.Port)
+	if len(config.Addresses) > 0 {
+		var err error
+		host, port, err = net.SplitHostPort(config.Addresses[0])
+		if err != nil {
+			return nil, fmt.Errorf("address %q: %w", config.Addresses[0], err)
+		}
+	}
+	args := []string{"--host", host}
+	if port != "" && port != "0" {
+		args = append(args, "--port", port)
+	}
+	if config.TLS != nil {
+		if config.TLS.CAFile != "" || config.TLS.CertFile != "" || config.TLS.KeyFile != "" {
+			return nil, fmt.Errorf("clickhouse-client takes TLS certificates from its config file, not from tls ca_file, cert_file or key_file")
+		}
+		args = append(args, "--secure")
+		if config.TLS.InsecureSkipVerify {
+			args = append(args, "--accept-invalid-certificate")
+		}
+	}
+	if config.Compression == "none" {
+		args = append(args, "--compression", "0")
+	}
+	return args, nil
+}
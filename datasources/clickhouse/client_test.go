@@ -0,0 +1,80 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+func TestClientArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  models.ClickHouseConfig
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "host and port",
+			config: models.ClickHouseConfig{Host: "db1", Port: 9440},
+			want:   []string{"--host", "db1", "--port", "9440"},
+		},
+		{
+			name:   "first address wins over host and port",
+			config: models.ClickHouseConfig{Host: "db1", Port: 9000, Addresses: []string{"db2:9001", "db3:9002"}},
+			want:   []string{"--host", "db2", "--port", "9001"},
+		},
+		{
+			name:   "ipv6 address",
+			config: models.ClickHouseConfig{Addresses: []string{"[::1]:9000"}},
+			want:   []string{"--host", "::1", "--port", "9000"},
+		},
+		{
+			name:   "tls",
+			config: models.ClickHouseConfig{Host: "db1", Port: 9440, TLS: &models.TLSConfig{}},
+			want:   []string{"--host", "db1", "--port", "9440", "--secure"},
+		},
+		{
+			name:   "tls without verification",
+			config: models.ClickHouseConfig{Host: "db1", Port: 9440, TLS: &models.TLSConfig{InsecureSkipVerify: true}},
+			want:   []string{"--host", "db1", "--port", "9440", "--secure", "--accept-invalid-certificate"},
+		},
+		{
+			name:   "no compression",
+			config: models.ClickHouseConfig{Host: "db1", Port: 9000, Compression: "none"},
+			want:   []string{"--host", "db1", "--port", "9000", "--compression", "0"},
+		},
+		{
+			name:    "http protocol",
+			config:  models.ClickHouseConfig{Host: "db1", Port: 8123, Protocol: models.ProtocolHTTP},
+			wantErr: true,
+		},
+		{
+			name:    "tls certificate files",
+			config:  models.ClickHouseConfig{Host: "db1", Port: 9440, TLS: &models.TLSConfig{CAFile: "ca.pem"}},
+			wantErr: true,
+		},
+		{
+			name:    "address without port",
+			config:  models.ClickHouseConfig{Addresses: []string{"db2"}},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ClientArgs(test.config)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ClientArgs() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClientArgs() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ClientArgs() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
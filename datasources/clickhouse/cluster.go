@@ -0,0 +1,167 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"go.uber.org/zap"
+)
+
+// OnCluster returns a service for the same database whose DDL runs ON CLUSTER
+// cluster, waiting up to timeout for every host to finish it. A zero timeout
+// keeps the server's distributed_ddl_task_timeout.
+func (cs ClickhouseService) OnCluster(cluster string, timeout time.Duration) *ClickhouseService {
+	cs.cluster, cs.ddlTimeout = cluster, timeout
+	return &cs
+}
+
+// Cluster returns the cluster the DDL of the service runs on, or "" when it
+// runs on the connected server only.
+func (cs ClickhouseService) Cluster() string {
+	return cs.cluster
+}
+
+const qualifiedName = "(?:`(?:[^`\\\\]|\\\\.)+`|[A-Za-z0-9_]+)(?:\\.(?:`(?:[^`\\\\]|\\\\.)+`|[A-Za-z0-9_]+))?"
+
+// clusterTargets match the part of a statement that ON CLUSTER follows.
+var clusterTargets = []*regexp.Regexp{
+	regexp.MustCompile("(?is)^\\s*CREATE\\s+(?:OR\\s+REPLACE\\s+)?(?:TABLE|VIEW|MATERIALIZED\\s+VIEW|LIVE\\s+VIEW|WINDOW\\s+VIEW|DICTIONARY|DATABASE)\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?" + qualifiedName),
+	regexp.MustCompile("(?is)^\\s*(?:ALTER|TRUNCATE|OPTIMIZE)\\s+TABLE\\s+(?:IF\\s+EXISTS\\s+)?" + qualifiedName),
+	regexp.MustCompile("(?is)^\\s*DROP\\s+(?:TABLE|VIEW|DICTIONARY|DATABASE)\\s+(?:IF\\s+EXISTS\\s+)?" + qualifiedName),
+	regexp.MustCompile("(?is)^\\s*EXCHANGE\\s+TABLES\\s+" + qualifiedName + "\\s+AND\\s+" + qualifiedName),
+	regexp.MustCompile("(?is)^\\s*RENAME\\s+TABLE\\s+" + qualifiedName + "\\s+TO\\s+" + qualifiedName),
+}
+
+var onClusterClause = regexp.MustCompile("(?i)^\\s+ON\\s+CLUSTER\\b")
+
+// ClusterQuery returns query with the ON CLUSTER clause of the service's
+// cluster. Queries that already have one, and statements that take none,
+// are returned as is, as is every query of a service without a cluster.
+func (cs ClickhouseService) ClusterQuery(query string) string {
+	if cs.cluster == "" {
+		return query
+	}
+	for _, target := range clusterTargets {
+		match := target.FindStringIndex(query)
+		if match == nil {
+			continue
+		}
+		if onClusterClause.MatchString(query[match[1]:]) {
+			return query
+		}
+		return query[:match[1]] + " ON CLUSTER " + quoteIdentifier(cs.cluster) + query[match[1]:]
+	}
+	return query
+}
+
+// DistributedTableQuery returns the statement creating wrapperName, a
+// Distributed table over tableName on every host of the cluster, or "" for a
+// service without a cluster.
+func (cs ClickhouseService) DistributedTableQuery(tableName string, wrapperName string) string {
+	if cs.cluster == "" {
+		return ""
+	}
+	return cs.ClusterQuery(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s AS %s.%s ENGINE = Distributed(%s, %s, %s, rand())",
+		cs.database, wrapperName, cs.database, tableName, quoteString(cs.cluster), quoteString(cs.database), quoteString(tableName)))
+}
+
+// exec runs a statement. On a cluster it runs ON CLUSTER and fails unless
+// every host reports that it finished the statement successfully.
+func (cs ClickhouseService) exec(ctx context.Context, query string) error {
+	if cs.cluster == "" {
+		return cs.Conn.Exec(ctx, query)
+	}
+	query = cs.ClusterQuery(query)
+	// Hosts that have not finished within the timeout are reported with a
+	// NULL status instead of failing the whole statement, so each can be
+	// named.
+	settings := clickhouse.Settings{"distributed_ddl_output_mode": "null_status_on_timeout"}
+	if cs.ddlTimeout > 0 {
+		settings["distributed_ddl_task_timeout"] = int64(math.Ceil(cs.ddlTimeout.Seconds()))
+	}
+	rows, err := cs.Conn.Query(clickhouse.Context(ctx, clickhouse.WithSettings(settings)), query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	statuses, err := scanHostStatuses(rows)
+	if err != nil {
+		return fmt.Errorf("reading host status: %w", err)
+	}
+	var failures []string
+	for _, status := range statuses {
+		cs.logger.Debug("Distributed DDL finished on host", zap.String("host", status.host), zap.Bool("finished", status.finished), zap.Int64("status", status.status))
+		switch {
+		case !status.finished:
+			failures = append(failures, status.host+": did not finish within distributed_ddl_task_timeout")
+		case status.status != 0:
+			failures = append(failures, fmt.Sprintf("%s: code %d: %s", status.host, status.status, status.error))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("on cluster %s, %d of %d hosts failed: %s", cs.cluster, len(failures), len(statuses), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// hostStatus is the outcome of a distributed DDL statement on one host.
+type hostStatus struct {
+	host     string
+	status   int64
+	error    string
+	finished bool
+}
+
+// scanHostStatuses reads the result set of a distributed DDL statement, one
+// row per host. Column types vary between server versions and with
+// distributed_ddl_output_mode, so the values are read by reflection.
+func scanHostStatuses(rows driver.Rows) ([]hostStatus, error) {
+	columns, types := rows.Columns(), rows.ColumnTypes()
+	var statuses []hostStatus
+	for rows.Next() {
+		values := make([]any, len(types))
+		for i, columnType := range types {
+			values[i] = reflect.New(columnType.ScanType()).Interface()
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		var status hostStatus
+		var port string
+		for i, name := range columns {
+			value := reflect.ValueOf(values[i]).Elem()
+			// Nullable columns scan into pointers, nil while the host has not finished.
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			switch name {
+			case "host":
+				status.host = fmt.Sprint(value.Interface())
+			case "port":
+				port = fmt.Sprint(value.Interface())
+			case "status":
+				if value.CanInt() {
+					status.status, status.finished = value.Int(), true
+				}
+			case "error":
+				status.error = fmt.Sprint(value.Interface())
+			}
+		}
+		if port != "" {
+			status.host += ":" + port
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
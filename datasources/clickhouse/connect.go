@@ -1,38 +1,198 @@
 package clickhouse
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"math"
+	"os"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/secret"
 )
 
+// defaultSettings are sent with every query unless the config overrides them.
+var defaultSettings = clickhouse.Settings{
+	"distributed_ddl_task_timeout":      1800,
+	"replication_alter_columns_timeout": 1800,
+	"max_query_size":                    1000000000,
+}
+
+var compressionMethods = map[string]clickhouse.CompressionMethod{
+	"":        clickhouse.CompressionLZ4,
+	"lz4":     clickhouse.CompressionLZ4,
+	"lz4hc":   clickhouse.CompressionLZ4HC,
+	"zstd":    clickhouse.CompressionZSTD,
+	"gzip":    clickhouse.CompressionGZIP,
+	"deflate": clickhouse.CompressionDeflate,
+	"br":      clickhouse.CompressionBrotli,
+	"none":    clickhouse.CompressionNone,
+}
+
+var protocols = map[models.Protocol]clickhouse.Protocol{
+	"":                    clickhouse.Native,
+	models.ProtocolNative: clickhouse.Native,
+	models.ProtocolHTTP:   clickhouse.HTTP,
+}
+
+var connOpenStrategies = map[models.ConnOpenStrategy]clickhouse.ConnOpenStrategy{
+	"":                        clickhouse.ConnOpenInOrder,
+	models.ConnOpenInOrder:    clickhouse.ConnOpenInOrder,
+	models.ConnOpenRoundRobin: clickhouse.ConnOpenRoundRobin,
+	models.ConnOpenRandom:     clickhouse.ConnOpenRandom,
+}
+
 func Connect(clickhouseConfig models.ClickHouseConfig) (driver.Conn, error) {
-	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{fmt.Sprintf("%s:%d", clickhouseConfig.Host, clickhouseConfig.Port)},
+	options, err := Options(clickhouseConfig)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := clickhouse.Open(options)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Options translates a config into driver options. Zero values keep the
+// defaults: 30s dial timeout, 30m read timeout, 10 open and 5 idle
+// connections living an hour, and LZ4 compression.
+func Options(clickhouseConfig models.ClickHouseConfig) (*clickhouse.Options, error) {
+	if err := ValidateConfig(clickhouseConfig); err != nil {
+		return nil, err
+	}
+	addresses := clickhouseConfig.Addresses
+	if len(addresses) == 0 {
+		addresses = []string{fmt.Sprintf("%s:%d", clickhouseConfig.Host, clickhouseConfig.Port)}
+	}
+	settings := make(clickhouse.Settings, len(defaultSettings)+len(clickhouseConfig.Settings))
+	for name, value := range defaultSettings {
+		settings[name] = value
+	}
+	for name, value := range clickhouseConfig.Settings {
+		settings[name] = settingValue(value)
+	}
+
+	options := &clickhouse.Options{
+		Protocol: protocols[clickhouseConfig.Protocol],
+		Addr:     addresses,
 		Auth: clickhouse.Auth{
 			Username: clickhouseConfig.Username,
 			Password: clickhouseConfig.Password,
 		},
-		Debug:           false,
-		DialTimeout:     time.Second * 30,
-		ReadTimeout:     time.Second * time.Duration(1800),
-		MaxOpenConns:    10,
-		MaxIdleConns:    5,
-		ConnMaxLifetime: time.Hour,
+		Debug:            false,
+		DialTimeout:      durationOr(clickhouseConfig.DialTimeout, 30*time.Second),
+		ReadTimeout:      durationOr(clickhouseConfig.ReadTimeout, 1800*time.Second),
+		MaxOpenConns:     intOr(clickhouseConfig.MaxOpenConns, 10),
+		MaxIdleConns:     intOr(clickhouseConfig.MaxIdleConns, 5),
+		ConnMaxLifetime:  durationOr(clickhouseConfig.ConnMaxLifetime, time.Hour),
+		ConnOpenStrategy: connOpenStrategies[clickhouseConfig.ConnOpenStrategy],
 		Compression: &clickhouse.Compression{
-			Method: clickhouse.CompressionLZ4,
+			Method: compressionMethods[clickhouseConfig.Compression],
 		},
-		Settings: clickhouse.Settings{
-			"distributed_ddl_task_timeout":      1800,
-			"replication_alter_columns_timeout": 1800,
-			"max_query_size":                    1000000000,
-		},
-	})
-	if err != nil {
-		return nil, err
+		Settings: settings,
 	}
-	return conn, nil
+	if clickhouseConfig.TLS != nil {
+		tlsConfig, err := TLSConfig(*clickhouseConfig.TLS)
+		if err != nil {
+			return nil, err
+		}
+		options.TLS = tlsConfig
+	}
+	return options, nil
+}
+
+// ValidateConfig checks the values of a config without touching the network
+// or the files it names.
+func ValidateConfig(clickhouseConfig models.ClickHouseConfig) error {
+	if len(clickhouseConfig.Addresses) == 0 && clickhouseConfig.Host == "" {
+		return fmt.Errorf("host or addresses is required")
+	}
+	if _, ok := protocols[clickhouseConfig.Protocol]; !ok {
+		return fmt.Errorf("unknown protocol %q, expected %q or %q", clickhouseConfig.Protocol, models.ProtocolNative, models.ProtocolHTTP)
+	}
+	if _, ok := connOpenStrategies[clickhouseConfig.ConnOpenStrategy]; !ok {
+		return fmt.Errorf("unknown conn_open_strategy %q, expected %q, %q or %q", clickhouseConfig.ConnOpenStrategy, models.ConnOpenInOrder, models.ConnOpenRoundRobin, models.ConnOpenRandom)
+	}
+	if _, ok := compressionMethods[clickhouseConfig.Compression]; !ok {
+		return fmt.Errorf("unknown compression %q, expected lz4, lz4hc, zstd, gzip, deflate, br or none", clickhouseConfig.Compression)
+	}
+	if clickhouseConfig.MaxOpenConns < 0 || clickhouseConfig.MaxIdleConns < 0 {
+		return fmt.Errorf("connection pool sizes must not be negative")
+	}
+	if clickhouseConfig.DialTimeout < 0 || clickhouseConfig.ReadTimeout < 0 || clickhouseConfig.ConnMaxLifetime < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if clickhouseConfig.PasswordFrom != nil {
+		if clickhouseConfig.Password != "" {
+			return fmt.Errorf("password and password_from are mutually exclusive")
+		}
+		if err := secret.Validate(*clickhouseConfig.PasswordFrom); err != nil {
+			return fmt.Errorf("password_from: %w", err)
+		}
+	}
+	if tlsConfig := clickhouseConfig.TLS; tlsConfig != nil && (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return fmt.Errorf("tls cert_file and key_file must be given together")
+	}
+	return nil
+}
+
+// TLSConfig loads the CA and client certificate named by config.
+func TLSConfig(config models.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// settingValue turns the numbers and booleans decoded from JSON or YAML into
+// values the driver sends as ClickHouse expects them.
+func settingValue(value any) any {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return value
+}
+
+func durationOr(value models.Duration, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return time.Duration(value)
+	}
+	return fallback
+}
+
+func intOr(value int, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
 }
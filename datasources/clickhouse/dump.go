@@ -0,0 +1,44 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportRows streams every row of the table to w, each rendered by the server
+// in the given row format and followed by a newline, and returns the number
+// of rows written.
+func (cs ClickhouseService) ExportRows(ctx context.Context, tableName string, format string, w io.Writer) (uint64, error) {
+	query := fmt.Sprintf("SELECT formatRowNoNewline('%s', *) FROM %s.%s", format, quoteIdentifier(cs.database), quoteIdentifier(tableName))
+
+	rows, err := cs.Conn.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count uint64
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return count, err
+		}
+		if _, err := io.WriteString(w, row+"\n"); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// InsertRows inserts rows, each one row in the given format such as
+// JSONEachRow, in a single INSERT.
+func (cs ClickhouseService) InsertRows(ctx context.Context, tableName string, format string, rows []string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf("INSERT INTO %s.%s FORMAT %s\n%s", quoteIdentifier(cs.database), quoteIdentifier(tableName), format, strings.Join(rows, "\n"))
+	return cs.Conn.Exec(ctx, query)
+}
@@ -0,0 +1,163 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// Schema is the part of a ClickHouse database the SchemaCloner reads and writes.
+type Schema interface {
+	Database() string
+	GetCreateTableQuery(ctx context.Context, tableName string) (string, error)
+	GetColumns(ctx context.Context, tableName string) ([]models.Column, error)
+	ExecuteDDL(ctx context.Context, query string) error
+}
+
+// EngineTranslator rewrites the engine of a create query, such as an
+// *engine.Translator.
+type EngineTranslator interface {
+	Translate(query string) (string, error)
+}
+
+// SchemaCloner recreates source tables on the destination from their
+// create_table_query, so engine, keys, TTLs, codecs, defaults, comments and
+// settings are preserved. The engine is rewritten by the translator, if any.
+type SchemaCloner struct {
+	logger      *zap.Logger
+	source      Schema
+	destination Schema
+	translator  EngineTranslator
+}
+
+func NewSchemaCloner(logger *zap.Logger, source Schema, destination Schema, translator EngineTranslator) *SchemaCloner {
+	return &SchemaCloner{
+		logger:      logger,
+		source:      source,
+		destination: destination,
+		translator:  translator,
+	}
+}
+
+// CreateTableQuery returns the DDL that creates destinationTable on the
+// destination as a copy of sourceTable.
+func (c *SchemaCloner) CreateTableQuery(ctx context.Context, sourceTable string, destinationTable string) (string, error) {
+	query, err := c.source.GetCreateTableQuery(ctx, sourceTable)
+	if err != nil {
+		return "", err
+	}
+	if query, err = c.translate(query); err != nil {
+		return "", err
+	}
+	return RewriteCreateQuery(query, c.destination.Database(), destinationTable)
+}
+
+func (c *SchemaCloner) translate(query string) (string, error) {
+	if c.translator == nil {
+		return query, nil
+	}
+	translated, err := c.translator.Translate(query)
+	if err != nil {
+		return "", fmt.Errorf("translating engine: %w", err)
+	}
+	return translated, nil
+}
+
+// CloneTable creates destinationTable and checks that its columns match the source.
+func (c *SchemaCloner) CloneTable(ctx context.Context, sourceTable string, destinationTable string) error {
+	query, err := c.CreateTableQuery(ctx, sourceTable, destinationTable)
+	if err != nil {
+		return err
+	}
+	c.logger.Info("Creating table", zap.String("table", destinationTable), zap.String("query", query))
+	if err := c.destination.ExecuteDDL(ctx, query); err != nil {
+		return err
+	}
+
+	sourceColumns, err := c.source.GetColumns(ctx, sourceTable)
+	if err != nil {
+		return err
+	}
+	destinationColumns, err := c.destination.GetColumns(ctx, destinationTable)
+	if err != nil {
+		return err
+	}
+	return compareColumns(sourceColumns, destinationColumns)
+}
+
+// CreateObjectQuery returns the DDL that creates destinationObject on the
+// destination as a copy of the view, materialized view or dictionary
+// sourceObject. References to objects of the source database are pointed at
+// the destination database, under the names rename gives them.
+func (c *SchemaCloner) CreateObjectQuery(ctx context.Context, sourceObject string, destinationObject string, rename func(string) string) (string, error) {
+	query, err := c.source.GetCreateTableQuery(ctx, sourceObject)
+	if err != nil {
+		return "", err
+	}
+	query = RewriteReferences(query, c.source.Database(), c.destination.Database(), rename)
+	if query, err = c.translate(query); err != nil {
+		return "", err
+	}
+	return RewriteCreateQuery(query, c.destination.Database(), destinationObject)
+}
+
+func compareColumns(source []models.Column, destination []models.Column) error {
+	if len(source) != len(destination) {
+		return fmt.Errorf("cloned table has %d columns, source has %d", len(destination), len(source))
+	}
+	for i := range source {
+		if source[i] != destination[i] {
+			return fmt.Errorf("cloned column %s (%s) does not match source column %s (%s)", destination[i].Name, destination[i].Type, source[i].Name, source[i].Type)
+		}
+	}
+	return nil
+}
+
+var createQueryHeader = regexp.MustCompile("(?is)^\\s*CREATE\\s+(TABLE|VIEW|MATERIALIZED\\s+VIEW|LIVE\\s+VIEW|WINDOW\\s+VIEW|DICTIONARY)\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?(?:`(?:[^`\\\\]|\\\\.)+`|[A-Za-z0-9_]+)\\.(?:`(?:[^`\\\\]|\\\\.)+`|[A-Za-z0-9_]+)")
+
+// RewriteCreateQuery replaces the qualified name in a CREATE statement with
+// database.table and makes the statement idempotent with IF NOT EXISTS.
+func RewriteCreateQuery(query string, database string, table string) (string, error) {
+	match := createQueryHeader.FindStringSubmatchIndex(query)
+	if match == nil {
+		return "", fmt.Errorf("unrecognised create query: %s", query)
+	}
+	kind := strings.ToUpper(strings.Join(strings.Fields(query[match[2]:match[3]]), " "))
+	header := fmt.Sprintf("CREATE %s IF NOT EXISTS %s.%s", kind, quoteIdentifier(database), quoteIdentifier(table))
+	return header + query[match[1]:], nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
+
+const identifierPattern = "(`(?:[^`\\\\]|\\\\.)+`|[A-Za-z0-9_]+)"
+
+// RewriteReferences points the qualified names of objects in database at
+// destinationDatabase, renaming the objects with rename, and rewrites the
+// DB of a ClickHouse dictionary source reading from database.
+func RewriteReferences(query string, database string, destinationDatabase string, rename func(string) string) string {
+	quotedDatabase := regexp.QuoteMeta(quoteIdentifier(database))
+	qualified := regexp.MustCompile("(^|[^A-Za-z0-9_.`])(?:" + quotedDatabase + "|" + regexp.QuoteMeta(database) + ")\\." + identifierPattern)
+	query = qualified.ReplaceAllStringFunc(query, func(match string) string {
+		parts := qualified.FindStringSubmatch(match)
+		name := unquoteIdentifier(parts[2])
+		if rename != nil {
+			name = rename(name)
+		}
+		return parts[1] + quoteIdentifier(destinationDatabase) + "." + quoteIdentifier(name)
+	})
+	dictionarySource := regexp.MustCompile("(?i)(\\bDB\\s+)'" + regexp.QuoteMeta(database) + "'")
+	return dictionarySource.ReplaceAllString(query, "${1}'"+strings.ReplaceAll(destinationDatabase, "'", "\\'")+"'")
+}
+
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 && strings.HasPrefix(name, "`") && strings.HasSuffix(name, "`") {
+		return strings.ReplaceAll(name[1:len(name)-1], "\\`", "`")
+	}
+	return name
+}
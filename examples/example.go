@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 
 	clickreplicator "github.com/prasannakumar414/click-replicator"
 	"github.com/prasannakumar414/click-replicator/models"
@@ -22,8 +24,9 @@ func main() {
         Database: "destination",
       }
     replicator := clickreplicator.NewClickReplicator(sourceConfig, destinationConfig)
-    err := replicator.ReplicateDatabase()
+    result, err := replicator.ReplicateDatabase(context.Background())
     if err != nil {
         log.Fatal(err)
     }
+    result.WriteText(os.Stdout)
 }
\ No newline at end of file
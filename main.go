@@ -1,45 +1,597 @@
 package clickreplicator
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/prasannakumar414/click-replicator/datasources/clickhouse"
 	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/checkpoint"
+	"github.com/prasannakumar414/click-replicator/services/dump"
+	"github.com/prasannakumar414/click-replicator/services/engine"
+	"github.com/prasannakumar414/click-replicator/services/filter"
 	"github.com/prasannakumar414/click-replicator/services/generator"
 	"github.com/prasannakumar414/click-replicator/services/inserter"
+	"github.com/prasannakumar414/click-replicator/services/mapping"
 	"github.com/prasannakumar414/click-replicator/services/replicator"
+	"github.com/prasannakumar414/click-replicator/services/secret"
+	"github.com/prasannakumar414/click-replicator/services/transfer"
+	"github.com/prasannakumar414/click-replicator/services/watermark"
 	"go.uber.org/zap"
 )
 
 type ClickReplicator struct {
-	sourceConfig      models.ClickHouseConfig
-	destinationConfig models.ClickHouseConfig
+	sourceConfig       models.ClickHouseConfig
+	destinationConfig  models.ClickHouseConfig
+	logger             *zap.Logger
+	source             replicator.DataSource
+	destination        replicator.DataSource
+	generator          replicator.Generator
+	inserter           replicator.Inserter
+	stagingDir         string
+	transferMethod     models.TransferMethod
+	tables             []models.TableConfig
+	watermarkFile      string
+	checkpointFile     string
+	checkpointDatabase string
+	checkpointTable    string
+	restart            bool
+	concurrency        int
+	order              models.TableOrder
+	maxSourceQueries   int
+	retries            int
+	chunkRows          uint64
+	strict             bool
+	includeTables      []string
+	excludeTables      []string
+	tableMapping       models.TableMapping
+	databases          []models.DatabaseMapping
+	allDatabases       bool
+	schemaPolicy       models.SchemaPolicy
+	engineTranslation  models.EngineTranslation
+	destinationCluster models.ClusterConfig
+	// redactor masks the resolved passwords; set by getLogger.
+	redactor *secret.Redactor
 }
 
-func NewClickReplicator(sourceConfig models.ClickHouseConfig, destinationConfig models.ClickHouseConfig) *ClickReplicator {
-	return &ClickReplicator{
+func NewClickReplicator(sourceConfig models.ClickHouseConfig, destinationConfig models.ClickHouseConfig, opts ...Option) *ClickReplicator {
+	f := &ClickReplicator{
 		sourceConfig:      sourceConfig,
 		destinationConfig: destinationConfig,
+		transferMethod:    models.TransferNative,
+		watermarkFile:     watermark.DefaultFileName,
+		checkpointFile:    checkpoint.DefaultFileName,
+		retries:           3,
+	}
+	for _, opt := range opts {
+		opt(f)
 	}
+	return f
 }
 
-func (f *ClickReplicator) ReplicateDatabase() error {
-	logger, _ := zap.NewProduction()
-	defer logger.Sync() // flushes buffer, if any
+// NewClickReplicatorFromJob builds a replicator from a job config, such as one
+// read with config.Load. opts are applied after the job and win over it.
+func NewClickReplicatorFromJob(job *models.JobConfig, opts ...Option) *ClickReplicator {
+	return NewClickReplicator(job.Source, job.Destination, append(JobOptions(job), opts...)...)
+}
+
+// ReplicateDatabase copies the source database to the destination and reports
+// what happened to every table. Cancelling ctx stops the run after the chunks
+// in flight; the next run resumes from the checkpoints.
+func (f *ClickReplicator) ReplicateDatabase(ctx context.Context) (_ *models.ReplicationResult, err error) {
+	defer func() { err = f.redactor.Error(err) }()
+	logger, sync, err := f.getLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sync()
 	logger.Info("Starting ClickHouse Transformer")
+	replicator, closeConns, err := f.newReplicator(logger)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConns()
+	return replicator.ReplicateDatabase(ctx)
+}
+
+// ReplicateDatabases copies several databases in one job, sharing the worker
+// pool, and reports them together. The databases are the ones given with
+// WithDatabases, every non-system database with WithAllDatabases, or else the
+// database of the source config.
+func (f *ClickReplicator) ReplicateDatabases(ctx context.Context) (_ *models.JobResult, err error) {
+	defer func() { err = f.redactor.Error(err) }()
+	logger, sync, err := f.getLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sync()
+	source, destination, closeConns, err := f.dataSources(logger)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConns()
+	pairs, err := f.databasePairs(ctx, source, destination)
+	if err != nil {
+		return nil, err
+	}
+	stores := f.newStores()
+	replicators := make([]*replicator.Replicator, 0, len(pairs))
+	for _, pair := range pairs {
+		pairSource, err := forDatabase(source, pair.Source)
+		if err != nil {
+			return nil, err
+		}
+		pairDestination, err := forDatabase(destination, pair.Destination)
+		if err != nil {
+			return nil, err
+		}
+		r, err := f.replicatorFor(logger, pairSource, pairDestination, stores)
+		if err != nil {
+			return nil, fmt.Errorf("database %s: %w", pair.Source, err)
+		}
+		replicators = append(replicators, r)
+	}
+	logger.Info("Starting replication job", zap.Int("databases", len(replicators)))
+	return replicator.NewJob(logger, f.concurrency, f.strict, replicators...).Run(ctx)
+}
+
+// databasePairs resolves the source and destination databases of a job.
+func (f *ClickReplicator) databasePairs(ctx context.Context, source replicator.DataSource, destination replicator.DataSource) ([]models.DatabaseMapping, error) {
+	if !f.allDatabases {
+		if len(f.databases) == 0 {
+			return []models.DatabaseMapping{{Source: source.Database(), Destination: destination.Database()}}, nil
+		}
+		pairs := make([]models.DatabaseMapping, len(f.databases))
+		for i, pair := range f.databases {
+			if pair.Destination == "" {
+				pair.Destination = pair.Source
+			}
+			pairs[i] = pair
+		}
+		return pairs, nil
+	}
+
+	lister, ok := source.(interface {
+		GetAllDatabases(ctx context.Context) ([]string, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("listing databases requires a ClickhouseService source")
+	}
+	databases, err := lister.GetAllDatabases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing databases: %w", err)
+	}
+	destinations := make(map[string]string, len(f.databases))
+	for _, pair := range f.databases {
+		destinations[pair.Source] = pair.Destination
+	}
+	pairs := make([]models.DatabaseMapping, 0, len(databases))
+	for _, database := range databases {
+		pair := models.DatabaseMapping{Source: database, Destination: destinations[database]}
+		if pair.Destination == "" {
+			pair.Destination = database
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// Verify compares the row counts and content hashes of every table and
+// partition on the source and destination. It writes nothing.
+func (f *ClickReplicator) Verify(ctx context.Context) (_ *models.VerificationReport, err error) {
+	defer func() { err = f.redactor.Error(err) }()
+	logger, sync, err := f.getLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sync()
+	replicator, closeConns, err := f.newReplicator(logger)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConns()
+	return replicator.Verify(ctx)
+}
+
+// Plan reports what ReplicateDatabase would do with every table, including
+// estimated rows and bytes and the DDL it would run, without writing anything.
+func (f *ClickReplicator) Plan(ctx context.Context) (_ *models.ReplicationPlan, err error) {
+	defer func() { err = f.redactor.Error(err) }()
+	logger, sync, err := f.getLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sync()
+	replicator, closeConns, err := f.newReplicator(logger)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConns()
+	return replicator.Plan(ctx)
+}
+
+// Diff compares the tables and columns of the source and destination
+// databases. It writes nothing.
+func (f *ClickReplicator) Diff(ctx context.Context) (_ *models.SchemaDiff, err error) {
+	defer func() { err = f.redactor.Error(err) }()
+	logger, sync, err := f.getLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sync()
+	replicator, closeConns, err := f.newReplicator(logger)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConns()
+	return replicator.Diff(ctx)
+}
+
+// Export writes every selected source table whose rows can be copied to dir,
+// as JSONEachRow rows in <table>.jsonl and the create query in <table>.sql.
+// It connects to the source only.
+func (f *ClickReplicator) Export(ctx context.Context, dir string) (_ *models.DumpResult, err error) {
+	defer func() { err = f.redactor.Error(err) }()
+	logger, sync, err := f.getLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sync()
+	source, closeSource, err := f.sourceDataSource(logger)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSource()
+	dumpSource, ok := source.(dump.Source)
+	if !ok {
+		return nil, fmt.Errorf("export requires a source implementing dump.Source")
+	}
+	tableFilter, err := filter.New(f.includeTables, f.excludeTables)
+	if err != nil {
+		return nil, fmt.Errorf("table filter: %w", err)
+	}
+	tables, err := source.GetAllTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching tables: %w", err)
+	}
+	tables, _ = tableFilter.Apply(tables)
+	copyable := tables[:0]
+	for _, table := range tables {
+		engine, err := source.GetTableEngine(ctx, table)
+		if err != nil {
+			return nil, fmt.Errorf("fetching engine of %s: %w", table, err)
+		}
+		if replicator.IsCopyableEngine(engine) {
+			copyable = append(copyable, table)
+		} else {
+			logger.Info("Not exporting table", zap.String("table", table), zap.String("engine", engine))
+		}
+	}
+	return f.redactDump(dump.NewExporter(logger, dumpSource, dir).Export(ctx, copyable))
+}
+
+// Import loads the tables exported to dir into the destination database,
+// creating missing tables and applying the table filter and mapping. Rows are
+// appended to existing tables. It connects to the destination only.
+func (f *ClickReplicator) Import(ctx context.Context, dir string) (_ *models.DumpResult, err error) {
+	defer func() { err = f.redactor.Error(err) }()
+	logger, sync, err := f.getLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sync()
+	destination, closeDestination, err := f.destinationDataSource(logger)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDestination()
+	dumpDestination, ok := destination.(dump.Destination)
+	if !ok {
+		return nil, fmt.Errorf("import requires a destination implementing dump.Destination")
+	}
+	if _, ok := destination.(*clickhouse.ClickhouseService); !ok && f.destinationCluster.Name != "" {
+		return nil, fmt.Errorf("WithDestinationCluster needs a ClickhouseService destination data source")
+	}
+	tableFilter, err := filter.New(f.includeTables, f.excludeTables)
+	if err != nil {
+		return nil, fmt.Errorf("table filter: %w", err)
+	}
+	mapper, err := mapping.New(f.tableMapping)
+	if err != nil {
+		return nil, fmt.Errorf("table mapping: %w", err)
+	}
+	importer := dump.NewImporter(logger, dumpDestination, dir, dump.DefaultBatchRows, mapper.Table)
+	tables, err := importer.Tables()
+	if err != nil {
+		return nil, err
+	}
+	tables, _ = tableFilter.Apply(tables)
+	if err := mapper.Check(tables); err != nil {
+		return nil, err
+	}
+	if err := destination.CreateDatabase(ctx); err != nil {
+		return nil, fmt.Errorf("creating database: %w", err)
+	}
+	return f.redactDump(importer.Import(ctx, tables))
+}
+
+// redactDump masks the passwords in the per-table errors of a dump.
+func (f *ClickReplicator) redactDump(result *models.DumpResult, err error) (*models.DumpResult, error) {
+	if result != nil {
+		for i := range result.Tables {
+			result.Tables[i].Error = f.redactor.String(result.Tables[i].Error)
+		}
+	}
+	return result, err
+}
+
+// getLogger returns the logger given with WithLogger or a new production
+// logger, together with the function that flushes it.
+// It first resolves the passwords of both configs, and the logger it returns
+// masks them in every entry.
+func (f *ClickReplicator) getLogger(ctx context.Context) (*zap.Logger, func(), error) {
+	if err := f.resolveSecrets(ctx); err != nil {
+		return nil, nil, err
+	}
+	if f.logger != nil {
+		return f.redactor.Logger(f.logger), func() {}, nil
+	}
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating logger: %w", err)
+	}
+	return f.redactor.Logger(logger), func() { logger.Sync() }, nil
+}
+
+// resolveSecrets reads the passwords named by PasswordFrom and builds the
+// redactor masking every password of the run.
+func (f *ClickReplicator) resolveSecrets(ctx context.Context) error {
+	sourceConfig, err := secret.ResolveConfig(ctx, f.sourceConfig)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	destinationConfig, err := secret.ResolveConfig(ctx, f.destinationConfig)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	f.sourceConfig, f.destinationConfig = sourceConfig, destinationConfig
+	f.redactor = secret.NewRedactor(sourceConfig.Password, destinationConfig.Password)
+	return nil
+}
+
+// newReplicator builds the replicator of a single database run. The returned
+// func closes the connections opened for it.
+func (f *ClickReplicator) newReplicator(logger *zap.Logger) (*replicator.Replicator, func(), error) {
+	source, destination, closeConns, err := f.dataSources(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := f.replicatorFor(logger, source, destination, f.newStores())
+	if err != nil {
+		closeConns()
+		return nil, nil, err
+	}
+	return r, closeConns, nil
+}
+
+// stores holds the file backed stores of a run. Each guards its file with its
+// own lock, so every replicator of a run must share the same ones.
+type stores struct {
+	watermarks  *watermark.FileStore
+	checkpoints *checkpoint.FileStore
+}
+
+func (f *ClickReplicator) newStores() stores {
+	return stores{
+		watermarks:  watermark.NewFileStore(f.watermarkFile),
+		checkpoints: checkpoint.NewFileStore(f.checkpointFile),
+	}
+}
+
+// dataSources returns the data sources given with WithSource and
+// WithDestination, connecting to the configured servers for the missing ones.
+// The returned func closes the connections opened here; data sources given
+// with the options are left to the caller.
+func (f *ClickReplicator) dataSources(logger *zap.Logger) (replicator.DataSource, replicator.DataSource, func(), error) {
+	source, closeSource, err := f.sourceDataSource(logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	destination, closeDestination, err := f.destinationDataSource(logger)
+	if err != nil {
+		closeSource()
+		return nil, nil, nil, err
+	}
+	closeConns := func() {
+		closeDestination()
+		closeSource()
+	}
+	if err := f.checkDataSources(source, destination); err != nil {
+		closeConns()
+		return nil, nil, nil, err
+	}
+	return source, destination, closeConns, nil
+}
+
+// checkDataSources rejects the options that need a ClickhouseService when a
+// data source given with WithSource or WithDestination is something else, so
+// none of them is ignored or fails halfway through a run.
+func (f *ClickReplicator) checkDataSources(source replicator.DataSource, destination replicator.DataSource) error {
+	_, sourceService := source.(*clickhouse.ClickhouseService)
+	_, destinationService := destination.(*clickhouse.ClickhouseService)
+	if sourceService && destinationService {
+		return nil
+	}
+	var unsupported []string
+	if f.transferMethod == models.TransferNative && (f.generator == nil || f.inserter == nil) {
+		unsupported = append(unsupported, "the native transfer (give WithGenerator and WithInserter instead)")
+	}
+	if len(f.databases) > 0 || f.allDatabases {
+		unsupported = append(unsupported, "WithDatabases and WithAllDatabases")
+	}
+	if !destinationService {
+		if f.checkpointTable != "" {
+			unsupported = append(unsupported, "WithCheckpointTable")
+		}
+		if f.destinationCluster.Name != "" {
+			unsupported = append(unsupported, "WithDestinationCluster")
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%s need ClickhouseService source and destination data sources", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+func (f *ClickReplicator) sourceDataSource(logger *zap.Logger) (replicator.DataSource, func(), error) {
+	if f.source != nil {
+		return f.source, func() {}, nil
+	}
 	sourceConn, err := clickhouse.Connect(f.sourceConfig)
 	if err != nil {
 		logger.Error("could not connect to source clickhouse")
-		return err
+		return nil, nil, err
+	}
+	return clickhouse.NewClickhouseService(sourceConn, logger, f.sourceConfig.Database), closeConn(logger, sourceConn), nil
+}
+
+func (f *ClickReplicator) destinationDataSource(logger *zap.Logger) (replicator.DataSource, func(), error) {
+	if f.destination != nil {
+		// A destination given without its cluster runs the DDL of the job on it.
+		if service, ok := f.destination.(*clickhouse.ClickhouseService); ok && f.destinationCluster.Name != "" && service.Cluster() == "" {
+			return service.OnCluster(f.destinationCluster.Name, time.Duration(f.destinationCluster.DDLTimeout)), func() {}, nil
+		}
+		return f.destination, func() {}, nil
 	}
 	destinationConn, err := clickhouse.Connect(f.destinationConfig)
 	if err != nil {
 		logger.Error("could not connect to destination clickhouse")
-		return err
-	}
-	sourceService := clickhouse.NewClickhouseService(sourceConn, logger, f.sourceConfig.Database)
-	destinationService := clickhouse.NewClickhouseService(destinationConn, logger, f.destinationConfig.Database)
-	generator := generator.NewGenerator(logger, f.sourceConfig)
-	inserter := inserter.NewInserter(f.destinationConfig)
-	replicator := replicator.NewReplicator(logger, sourceService, destinationService, generator, inserter)
-	err = replicator.ReplicateDatabase()
-	return err
+		return nil, nil, err
+	}
+	service := clickhouse.NewClickhouseService(destinationConn, logger, f.destinationConfig.Database)
+	if f.destinationCluster.Name != "" {
+		service = service.OnCluster(f.destinationCluster.Name, time.Duration(f.destinationCluster.DDLTimeout))
+	}
+	return service, closeConn(logger, destinationConn), nil
+}
+
+// closeConn returns a func closing conn, logging a failure to close it.
+func closeConn(logger *zap.Logger, conn driver.Conn) func() {
+	return func() {
+		if err := conn.Close(); err != nil {
+			logger.Warn("could not close clickhouse connection", zap.Error(err))
+		}
+	}
+}
+
+// replicatorFor builds the replicator copying source to destination.
+func (f *ClickReplicator) replicatorFor(logger *zap.Logger, source replicator.DataSource, destination replicator.DataSource, stores stores) (*replicator.Replicator, error) {
+	gen, ins := f.generator, f.inserter
+	if gen == nil || ins == nil {
+		var (
+			defaultGen replicator.Generator
+			defaultIns replicator.Inserter
+		)
+		switch f.transferMethod {
+		case models.TransferNative:
+			sourceConn, destinationConn, err := connections(source, destination)
+			if err != nil {
+				return nil, fmt.Errorf("native transfer: %w; provide WithGenerator and WithInserter instead", err)
+			}
+			native := transfer.NewTransfer(logger, sourceConn, source.Database(), destinationConn, destination.Database(), transfer.DefaultBlockSize)
+			defaultGen, defaultIns = native, native
+		case models.TransferClient:
+			sourceConfig, destinationConfig := f.sourceConfig, f.destinationConfig
+			sourceConfig.Database, destinationConfig.Database = source.Database(), destination.Database()
+			defaultGen = generator.NewGenerator(logger, sourceConfig, f.stagingDir)
+			defaultIns = inserter.NewInserter(destinationConfig)
+		default:
+			return nil, fmt.Errorf("unknown transfer method %q", f.transferMethod)
+		}
+		if gen == nil {
+			gen = defaultGen
+		}
+		if ins == nil {
+			ins = defaultIns
+		}
+	}
+	tableFilter, err := filter.New(f.includeTables, f.excludeTables)
+	if err != nil {
+		return nil, fmt.Errorf("table filter: %w", err)
+	}
+	mapper, err := mapping.New(f.tableMapping)
+	if err != nil {
+		return nil, fmt.Errorf("table mapping: %w", err)
+	}
+	var distributedSuffix string
+	if f.destinationCluster.DistributedTables {
+		distributedSuffix = f.destinationCluster.DistributedSuffix
+		if distributedSuffix == "" {
+			distributedSuffix = models.DefaultDistributedSuffix
+		}
+	}
+	translator, err := engine.New(f.engineTranslation)
+	if err != nil {
+		return nil, fmt.Errorf("engine translation: %w", err)
+	}
+	cloner := clickhouse.NewSchemaCloner(logger, source, destination, translator)
+	var checkpoints replicator.CheckpointStore = stores.checkpoints
+	if f.checkpointTable != "" {
+		service, ok := destination.(*clickhouse.ClickhouseService)
+		if !ok {
+			return nil, fmt.Errorf("checkpoint table requires a ClickhouseService destination")
+		}
+		database := f.checkpointDatabase
+		if database == "" {
+			database = destination.Database()
+		}
+		checkpoints = checkpoint.NewTableStore(service.Conn, database, f.checkpointTable)
+	}
+	return replicator.NewReplicator(logger, source, destination, gen, ins, cloner, replicator.Options{
+		Tables:            f.tables,
+		Watermarks:        stores.watermarks,
+		Checkpoints:       checkpoints,
+		Restart:           f.restart,
+		Concurrency:       f.concurrency,
+		Order:             f.order,
+		MaxSourceQueries:  f.maxSourceQueries,
+		Retries:           f.retries,
+		ChunkRows:         f.chunkRows,
+		Strict:            f.strict,
+		Filter:            tableFilter,
+		Mapping:           mapper,
+		SchemaPolicy:      f.schemaPolicy,
+		Redactor:          f.redactor,
+		DistributedSuffix: distributedSuffix,
+	}), nil
+}
+
+// forDatabase returns the data source of database on the server behind
+// dataSource.
+func forDatabase(dataSource replicator.DataSource, database string) (replicator.DataSource, error) {
+	if dataSource.Database() == database {
+		return dataSource, nil
+	}
+	service, ok := dataSource.(*clickhouse.ClickhouseService)
+	if !ok {
+		return nil, fmt.Errorf("replicating database %s requires a ClickhouseService data source", database)
+	}
+	return service.ForDatabase(database), nil
+}
+
+// connections returns the driver connections behind source and destination,
+// which the native transfer reads from and writes to directly.
+func connections(source replicator.DataSource, destination replicator.DataSource) (driver.Conn, driver.Conn, error) {
+	sourceService, ok := source.(*clickhouse.ClickhouseService)
+	if !ok {
+		return nil, nil, fmt.Errorf("source is not a ClickhouseService")
+	}
+	destinationService, ok := destination.(*clickhouse.ClickhouseService)
+	if !ok {
+		return nil, nil, fmt.Errorf("destination is not a ClickhouseService")
+	}
+	return sourceService.Conn, destinationService.Conn, nil
 }
@@ -0,0 +1,13 @@
+package models
+
+// CheckpointStatus records how far a unit of replication work got.
+type CheckpointStatus string
+
+const (
+	// CheckpointNone means the work has not been attempted in the current run.
+	CheckpointNone CheckpointStatus = ""
+	// CheckpointStarted means rows may have been written but the work did not finish.
+	CheckpointStarted CheckpointStatus = "started"
+	// CheckpointDone means the work finished and must not be repeated.
+	CheckpointDone CheckpointStatus = "done"
+)
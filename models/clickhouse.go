@@ -6,4 +6,69 @@ type ClickHouseConfig struct {
 	Username string `json:"username" yaml:"username"`
 	Password string `json:"password" yaml:"password"`
 	Database string `json:"database" yaml:"database"`
-}
\ No newline at end of file
+	// PasswordFrom resolves the password at run time instead of Password.
+	PasswordFrom *SecretSource `json:"password_from,omitempty" yaml:"password_from,omitempty"`
+	// Addresses lists host:port pairs to dial instead of Host and Port. They
+	// are tried according to ConnOpenStrategy.
+	Addresses        []string         `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+	ConnOpenStrategy ConnOpenStrategy `json:"conn_open_strategy,omitempty" yaml:"conn_open_strategy,omitempty"`
+	Protocol         Protocol         `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	// TLS enables TLS when set, even if all its fields are empty.
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+	// Compression is one of lz4 (the default), lz4hc, zstd, gzip, deflate, br or none.
+	Compression     string   `json:"compression,omitempty" yaml:"compression,omitempty"`
+	DialTimeout     Duration `json:"dial_timeout,omitempty" yaml:"dial_timeout,omitempty"`
+	ReadTimeout     Duration `json:"read_timeout,omitempty" yaml:"read_timeout,omitempty"`
+	MaxOpenConns    int      `json:"max_open_conns,omitempty" yaml:"max_open_conns,omitempty"`
+	MaxIdleConns    int      `json:"max_idle_conns,omitempty" yaml:"max_idle_conns,omitempty"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime,omitempty" yaml:"conn_max_lifetime,omitempty"`
+	// Settings are sent with every query and win over the built-in defaults.
+	Settings map[string]any `json:"settings,omitempty" yaml:"settings,omitempty"`
+}
+
+// SecretSource names where a secret is read from. Exactly one field is set.
+type SecretSource struct {
+	// Env is the name of an environment variable holding the secret.
+	Env string `json:"env,omitempty" yaml:"env,omitempty"`
+	// File is a file holding the secret; trailing newlines are dropped.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Command is run without a shell and its output, minus trailing
+	// newlines, is the secret.
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+}
+
+// TLSConfig describes how to secure a connection. Empty fields keep the
+// system defaults.
+type TLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty" yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Protocol is the wire protocol used to talk to ClickHouse.
+type Protocol string
+
+const (
+	// ProtocolNative is the native TCP protocol, the default.
+	ProtocolNative Protocol = "native"
+	ProtocolHTTP   Protocol = "http"
+)
+
+// ConnOpenStrategy decides which of several addresses a new connection dials.
+type ConnOpenStrategy string
+
+const (
+	// ConnOpenInOrder dials the first reachable address, the default.
+	ConnOpenInOrder    ConnOpenStrategy = "in-order"
+	ConnOpenRoundRobin ConnOpenStrategy = "round-robin"
+	ConnOpenRandom     ConnOpenStrategy = "random"
+)
+
+// DatabaseMapping pairs a source database with the destination database it is
+// replicated to. An empty Destination keeps the source name.
+type DatabaseMapping struct {
+	Source      string `json:"source" yaml:"source"`
+	Destination string `json:"destination,omitempty" yaml:"destination,omitempty"`
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// DiffStatus is how an object compares between the source and destination.
+type DiffStatus string
+
+const (
+	DiffSame    DiffStatus = "same"
+	DiffChanged DiffStatus = "changed"
+	// DiffMissing means the object exists on the source only.
+	DiffMissing DiffStatus = "missing"
+	// DiffExtra means the object exists on the destination only.
+	DiffExtra DiffStatus = "extra"
+)
+
+// Aspects of a column that ColumnDiff.Changes lists.
+const (
+	ColumnType     = "type"
+	ColumnNullable = "nullable"
+	ColumnDefault  = "default"
+)
+
+// ColumnDiff compares one column of a table.
+type ColumnDiff struct {
+	Column          string     `json:"column"`
+	Status          DiffStatus `json:"status"`
+	SourceType      string     `json:"source_type,omitempty"`
+	DestinationType string     `json:"destination_type,omitempty"`
+	// The defaults are rendered as kind and expression, such as "DEFAULT now()".
+	SourceDefault      string `json:"source_default,omitempty"`
+	DestinationDefault string `json:"destination_default,omitempty"`
+	// Changes lists what differs in a changed column: type, nullable and default.
+	Changes []string `json:"changes,omitempty"`
+}
+
+// PropertyDiff compares one table level property, such as the engine.
+type PropertyDiff struct {
+	Property    string `json:"property"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// TableDiff compares the schema of one table.
+type TableDiff struct {
+	Table       string       `json:"table"`
+	Destination string       `json:"destination"`
+	Status      DiffStatus   `json:"status"`
+	Error       string       `json:"error,omitempty"`
+	Columns     []ColumnDiff `json:"columns,omitempty"`
+	// Properties lists the table level properties that differ.
+	Properties []PropertyDiff `json:"properties,omitempty"`
+	// Migration holds the statements that bring the destination table in
+	// line. Statements that lose data or cannot run in place are commented out.
+	Migration []string `json:"migration,omitempty"`
+}
+
+// SchemaDiff compares the schemas of the source and destination databases.
+type SchemaDiff struct {
+	SourceDatabase      string      `json:"source_database"`
+	DestinationDatabase string      `json:"destination_database"`
+	Match               bool        `json:"match"`
+	Tables              []TableDiff `json:"tables"`
+}
+
+// WriteText renders the differences, one line per table and per differing
+// column or property, followed by the migration script.
+func (d *SchemaDiff) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Schema diff %s -> %s\n\n", d.SourceDatabase, d.DestinationDatabase)
+	fmt.Fprintln(tw, "TABLE\tCOLUMN\tSTATUS\tSOURCE\tDESTINATION")
+	differences := 0
+	for _, table := range d.Tables {
+		status := string(table.Status)
+		if table.Error != "" {
+			status = "error: " + table.Error
+		}
+		fmt.Fprintf(tw, "%s\t\t%s\t\t\n", tableLabel(table.Table, table.Destination), status)
+		for _, property := range table.Properties {
+			fmt.Fprintf(tw, "\t(%s)\t%s\t%s\t%s\n", property.Property, DiffChanged, property.Source, property.Destination)
+		}
+		for _, column := range table.Columns {
+			if column.Status == DiffSame {
+				continue
+			}
+			status := string(column.Status)
+			if len(column.Changes) > 0 {
+				status += " (" + strings.Join(column.Changes, ", ") + ")"
+			}
+			fmt.Fprintf(tw, "\t%s\t%s\t%s\t%s\n", column.Column, status, columnSpec(column.SourceType, column.SourceDefault), columnSpec(column.DestinationType, column.DestinationDefault))
+		}
+		if table.Status != DiffSame {
+			differences++
+		}
+	}
+	fmt.Fprintf(tw, "\n%d tables, %d differ\n", len(d.Tables), differences)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if d.Match {
+		return nil
+	}
+	fmt.Fprintln(w, "\nMigration:")
+	return d.WriteMigration(w)
+}
+
+// WriteMigration writes the migration statements of every table as a script.
+func (d *SchemaDiff) WriteMigration(w io.Writer) error {
+	for _, table := range d.Tables {
+		if len(table.Migration) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "\n-- %s\n", tableLabel(table.Table, table.Destination)); err != nil {
+			return err
+		}
+		for _, statement := range table.Migration {
+			if !strings.HasPrefix(statement, "--") {
+				statement += ";"
+			}
+			if _, err := fmt.Fprintln(w, statement); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func columnSpec(columnType string, columnDefault string) string {
+	if columnDefault == "" {
+		return columnType
+	}
+	return columnType + " " + columnDefault
+}
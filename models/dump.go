@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// DumpTable reports the export or import of one table file.
+type DumpTable struct {
+	Table       string `json:"table"`
+	Destination string `json:"destination,omitempty"`
+	File        string `json:"file"`
+	Rows        uint64 `json:"rows"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DumpResult reports an export to, or an import from, a directory of table files.
+type DumpResult struct {
+	Directory string      `json:"directory"`
+	Tables    []DumpTable `json:"tables"`
+}
+
+// Failed returns the number of tables that could not be exported or imported.
+func (r *DumpResult) Failed() int {
+	failed := 0
+	for _, table := range r.Tables {
+		if table.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteText renders the result as a table with one line per table.
+func (r *DumpResult) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tFILE\tROWS\tSTATUS")
+	var rows uint64
+	for _, table := range r.Tables {
+		status := "ok"
+		if table.Error != "" {
+			status = "error: " + table.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", tableLabel(table.Table, table.Destination), table.File, table.Rows, status)
+		rows += table.Rows
+	}
+	fmt.Fprintf(tw, "\n%d tables, %d rows, %d failed\n", len(r.Tables), rows, r.Failed())
+	return tw.Flush()
+}
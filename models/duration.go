@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Duration is a time.Duration that reads and writes strings such as "90s" or
+// "24h" in JSON and YAML.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
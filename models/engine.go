@@ -0,0 +1,41 @@
+package models
+
+// EngineTranslation rewrites the engine of cloned tables, for copies between
+// servers of different topologies such as a replicated cluster and a single
+// node. The rules of the presets are applied first, then Rules, in order.
+type EngineTranslation struct {
+	Presets []string     `json:"presets,omitempty" yaml:"presets,omitempty"`
+	Rules   []EngineRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Macros replaces {name} in the ZooKeeper path and replica name of
+	// replicated engines, for destinations that do not define the macro.
+	Macros map[string]string `json:"macros,omitempty" yaml:"macros,omitempty"`
+}
+
+// EngineRuleKind names what an engine rule does.
+type EngineRuleKind string
+
+const (
+	// RuleUnreplicate turns ReplicatedXMergeTree into XMergeTree.
+	RuleUnreplicate EngineRuleKind = "unreplicate"
+	// RuleReplicate turns XMergeTree into ReplicatedXMergeTree.
+	RuleReplicate EngineRuleKind = "replicate"
+	// RuleZooKeeperPath sets the ZooKeeper path and replica name of
+	// ReplicatedXMergeTree engines.
+	RuleZooKeeperPath EngineRuleKind = "zookeeper-path"
+	// RuleDistributedCluster points Distributed engines at another cluster.
+	RuleDistributedCluster EngineRuleKind = "distributed-cluster"
+)
+
+// EngineRule is one step of an EngineTranslation.
+type EngineRule struct {
+	Rule EngineRuleKind `json:"rule" yaml:"rule"`
+	// ZooKeeperPath and Replica are the arguments given to replicated engines
+	// by the replicate and zookeeper-path rules. They may hold macros such as
+	// {shard}, {replica}, {database} and {table}.
+	ZooKeeperPath string `json:"zookeeper_path,omitempty" yaml:"zookeeper_path,omitempty"`
+	Replica       string `json:"replica,omitempty" yaml:"replica,omitempty"`
+	// From limits a distributed-cluster rule to one cluster, To is the
+	// cluster it points at instead.
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+	To   string `json:"to,omitempty" yaml:"to,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "fmt"
+
+// SchemaChangeKind is how a column changed on the source.
+type SchemaChangeKind string
+
+const (
+	ChangeAdded   SchemaChangeKind = "added"
+	ChangeDropped SchemaChangeKind = "dropped"
+	// ChangeRenamed is a column missing on the source replaced, at the same
+	// position, with the same type and default and before the same column, by
+	// one missing on the destination.
+	ChangeRenamed SchemaChangeKind = "renamed"
+	ChangeRetyped SchemaChangeKind = "retyped"
+)
+
+// SchemaChange is a column change found on the source before a copy,
+// together with the statement that carries it over to the destination.
+type SchemaChange struct {
+	Kind   SchemaChangeKind `json:"kind"`
+	Column string           `json:"column"`
+	// PreviousName is the destination name of a renamed column.
+	PreviousName string `json:"previous_name,omitempty"`
+	Type         string `json:"type,omitempty"`
+	// PreviousType is the destination type of a retyped column.
+	PreviousType string `json:"previous_type,omitempty"`
+	Statement    string `json:"statement"`
+	Applied      bool   `json:"applied"`
+}
+
+func (c SchemaChange) String() string {
+	switch c.Kind {
+	case ChangeRenamed:
+		return fmt.Sprintf("column %s renamed to %s", c.PreviousName, c.Column)
+	case ChangeRetyped:
+		return fmt.Sprintf("column %s retyped from %s to %s", c.Column, c.PreviousType, c.Type)
+	case ChangeDropped:
+		return fmt.Sprintf("column %s dropped", c.Column)
+	default:
+		return fmt.Sprintf("column %s %s added", c.Column, c.Type)
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+// JobConfig describes a whole replication job as read from a config file.
+// Zero values keep the defaults of NewClickReplicator.
+type JobConfig struct {
+	Source      ClickHouseConfig `json:"source" yaml:"source"`
+	Destination ClickHouseConfig `json:"destination" yaml:"destination"`
+	// Databases replicates these databases instead of the source database.
+	Databases []DatabaseMapping `json:"databases,omitempty" yaml:"databases,omitempty"`
+	// AllDatabases replicates every non-system database of the source.
+	AllDatabases bool           `json:"all_databases,omitempty" yaml:"all_databases,omitempty"`
+	Include      []string       `json:"include,omitempty" yaml:"include,omitempty"`
+	Exclude      []string       `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	Mapping      TableMapping   `json:"mapping,omitempty" yaml:"mapping,omitempty"`
+	Tables       []TableConfig  `json:"tables,omitempty" yaml:"tables,omitempty"`
+	Transfer     TransferMethod `json:"transfer,omitempty" yaml:"transfer,omitempty"`
+	StagingDir   string         `json:"staging_dir,omitempty" yaml:"staging_dir,omitempty"`
+	Concurrency  int            `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	Order        TableOrder     `json:"order,omitempty" yaml:"order,omitempty"`
+	// MaxSourceQueries caps the table reads running on the source at once.
+	MaxSourceQueries int `json:"max_source_queries,omitempty" yaml:"max_source_queries,omitempty"`
+	// Retries is a pointer so that zero retries can be told apart from the default.
+	Retries     *int             `json:"retries,omitempty" yaml:"retries,omitempty"`
+	ChunkRows   uint64           `json:"chunk_rows,omitempty" yaml:"chunk_rows,omitempty"`
+	Strict      bool             `json:"strict,omitempty" yaml:"strict,omitempty"`
+	Restart     bool             `json:"restart,omitempty" yaml:"restart,omitempty"`
+	Watermarks  string           `json:"watermark_file,omitempty" yaml:"watermark_file,omitempty"`
+	Checkpoints CheckpointConfig `json:"checkpoints,omitempty" yaml:"checkpoints,omitempty"`
+	// SchemaPolicy decides what a run does when source columns changed.
+	SchemaPolicy SchemaPolicy `json:"schema_policy,omitempty" yaml:"schema_policy,omitempty"`
+	// Engines rewrites the engine of cloned tables.
+	Engines EngineTranslation `json:"engines,omitempty" yaml:"engines,omitempty"`
+	// DestinationCluster runs the DDL of the destination on a whole cluster.
+	DestinationCluster ClusterConfig `json:"destination_cluster,omitempty" yaml:"destination_cluster,omitempty"`
+}
+
+// ClusterConfig makes a run create and alter destination tables ON CLUSTER
+// Name, so every replica and shard gets the schema. Rows are still inserted
+// through the connected server.
+type ClusterConfig struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// DDLTimeout is the distributed_ddl_task_timeout a statement waits for
+	// every host; zero keeps the server's.
+	DDLTimeout Duration `json:"ddl_timeout,omitempty" yaml:"ddl_timeout,omitempty"`
+	// DistributedTables creates a Distributed table over every table, named
+	// after it with DistributedSuffix.
+	DistributedTables bool   `json:"distributed_tables,omitempty" yaml:"distributed_tables,omitempty"`
+	DistributedSuffix string `json:"distributed_suffix,omitempty" yaml:"distributed_suffix,omitempty"`
+}
+
+// DefaultDistributedSuffix names the Distributed tables of a ClusterConfig
+// without a DistributedSuffix.
+const DefaultDistributedSuffix = "_distributed"
+
+// CheckpointConfig chooses where checkpoints are kept: in File, or in a
+// table on the destination when Table is set.
+type CheckpointConfig struct {
+	File     string `json:"file,omitempty" yaml:"file,omitempty"`
+	Database string `json:"database,omitempty" yaml:"database,omitempty"`
+	Table    string `json:"table,omitempty" yaml:"table,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// TableMapping renames source tables on the destination. An explicit pair
+// wins over everything else; otherwise the first matching rewrite is applied
+// and the result is placed into the template.
+type TableMapping struct {
+	// Tables maps source table names to destination table names.
+	Tables map[string]string `json:"tables,omitempty" yaml:"tables,omitempty"`
+	// Rewrites are tried in order and the first whose pattern matches the
+	// whole name is applied.
+	Rewrites []Rewrite `json:"rewrites,omitempty" yaml:"rewrites,omitempty"`
+	// Template builds the destination name from the (rewritten) source name,
+	// which replaces {table}, e.g. "stg_{table}" or "{table}_v2".
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+// Rewrite renames the tables matching a regular expression. Replacement may
+// refer to capture groups as $1 or ${name}.
+type Rewrite struct {
+	Pattern     string `json:"pattern" yaml:"pattern"`
+	Replacement string `json:"replacement" yaml:"replacement"`
+}
+
+// tableLabel names a table in text reports, showing the destination name when
+// the table was renamed.
+func tableLabel(source string, destination string) string {
+	if destination == "" || destination == source {
+		return source
+	}
+	return source + " -> " + destination
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// PlanAction is what a replication run would do with a table.
+type PlanAction string
+
+const (
+	ActionCreate          PlanAction = "create"
+	ActionSkip            PlanAction = "skip"
+	ActionFullCopy        PlanAction = "full-copy"
+	ActionIncrementalCopy PlanAction = "incremental-copy"
+	ActionUnsupported     PlanAction = "unsupported"
+)
+
+// TablePlan describes the planned handling of one source table.
+type TablePlan struct {
+	Table          string     `json:"table"`
+	Destination    string     `json:"destination"`
+	Engine         string     `json:"engine"`
+	Kind           ObjectKind `json:"kind,omitempty"`
+	Action         PlanAction `json:"action"`
+	Mode           LoadMode   `json:"mode,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+	EstimatedRows  uint64     `json:"estimated_rows"`
+	EstimatedBytes uint64     `json:"estimated_bytes"`
+	DDL            []string   `json:"ddl,omitempty"`
+	// SchemaChanges lists the column changes found on the source.
+	SchemaChanges []SchemaChange `json:"schema_changes,omitempty"`
+	// DestinationEngine is the engine the table is created with when the
+	// engine translation changes it.
+	DestinationEngine string `json:"destination_engine,omitempty"`
+}
+
+// ReplicationPlan is the dry-run outcome of a replication: nothing in it has
+// been executed.
+type ReplicationPlan struct {
+	SourceDatabase      string      `json:"source_database"`
+	DestinationDatabase string      `json:"destination_database"`
+	DDL                 []string    `json:"ddl,omitempty"`
+	Tables              []TablePlan `json:"tables"`
+	// Excluded lists the source tables left out by the include and exclude patterns.
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// WriteText renders the plan as a table followed by the DDL it would run.
+func (p *ReplicationPlan) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Replication plan %s -> %s\n\n", p.SourceDatabase, p.DestinationDatabase)
+	fmt.Fprintln(tw, "TABLE\tENGINE\tACTION\tMODE\tEST. ROWS\tEST. BYTES\tREASON")
+	var (
+		rows, bytes uint64
+		ddl         = append([]string(nil), p.DDL...)
+	)
+	for _, table := range p.Tables {
+		engine := table.Engine
+		if table.DestinationEngine != "" {
+			engine += " -> " + table.DestinationEngine
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", tableLabel(table.Table, table.Destination), engine, table.Action, table.Mode, table.EstimatedRows, table.EstimatedBytes, table.Reason)
+		rows += table.EstimatedRows
+		bytes += table.EstimatedBytes
+		ddl = append(ddl, table.DDL...)
+	}
+	fmt.Fprintf(tw, "\n%d tables, about %d rows and %d bytes to copy\n", len(p.Tables), rows, bytes)
+	if len(p.Excluded) > 0 {
+		fmt.Fprintf(tw, "%d tables excluded: %s\n", len(p.Excluded), strings.Join(p.Excluded, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	header := false
+	for _, table := range p.Tables {
+		for _, change := range table.SchemaChanges {
+			if !header {
+				fmt.Fprintln(w, "\nSchema changes:")
+				header = true
+			}
+			fmt.Fprintf(w, "  %s: %s\n", tableLabel(table.Table, table.Destination), change)
+		}
+	}
+	if len(ddl) > 0 {
+		if _, err := fmt.Fprintf(w, "\nDDL:\n%s;\n", strings.Join(ddl, ";\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
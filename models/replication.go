@@ -0,0 +1,36 @@
+package models
+
+// TransferMethod selects how rows are moved from the source to the destination.
+type TransferMethod string
+
+const (
+	// TransferNative streams blocks through the clickhouse-go driver.
+	TransferNative TransferMethod = "native"
+	// TransferClient exports to a JSONEachRow file and pipes it through clickhouse-client.
+	TransferClient TransferMethod = "clickhouse-client"
+)
+
+// TableOrder decides which tables a replication run starts first.
+type TableOrder string
+
+const (
+	// OrderByName replicates tables in the order the source lists them.
+	OrderByName TableOrder = ""
+	// OrderLargestFirst starts the biggest tables first so they do not become the tail of the run.
+	OrderLargestFirst TableOrder = "largest-first"
+	// OrderSmallestFirst finishes as many tables as possible early.
+	OrderSmallestFirst TableOrder = "smallest-first"
+)
+
+// SchemaPolicy decides what a run does when the columns of a source table no
+// longer match its destination table.
+type SchemaPolicy string
+
+const (
+	// SchemaWarn logs and reports the changes and copies without them, the default.
+	SchemaWarn SchemaPolicy = "warn"
+	// SchemaApply alters the destination table before copying.
+	SchemaApply SchemaPolicy = "apply"
+	// SchemaFail reports the changes and fails the table.
+	SchemaFail SchemaPolicy = "fail"
+)
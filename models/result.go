@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// TableStatus is the outcome of replicating one table.
+type TableStatus string
+
+const (
+	// StatusCreated means the table was created on the destination and its rows copied.
+	StatusCreated TableStatus = "created"
+	StatusCopied  TableStatus = "copied"
+	StatusSkipped TableStatus = "skipped"
+	StatusFailed  TableStatus = "failed"
+)
+
+// TableResult reports what happened to one table during a run.
+type TableResult struct {
+	Table       string      `json:"table"`
+	Destination string      `json:"destination"`
+	Kind        ObjectKind  `json:"kind,omitempty"`
+	Status      TableStatus `json:"status"`
+	Action      PlanAction  `json:"action,omitempty"`
+	Mode        LoadMode    `json:"mode,omitempty"`
+	Reason      string      `json:"reason,omitempty"`
+	// Rows is the number of rows written to the destination.
+	Rows uint64 `json:"rows"`
+	// Bytes is the on-disk size of the source data that was copied.
+	Bytes    uint64   `json:"bytes"`
+	Duration Duration `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+	// SchemaChanges lists the column changes found before the copy.
+	SchemaChanges []SchemaChange `json:"schema_changes,omitempty"`
+}
+
+// ReplicationResult reports a whole replication run.
+type ReplicationResult struct {
+	SourceDatabase      string        `json:"source_database"`
+	DestinationDatabase string        `json:"destination_database"`
+	StartedAt           time.Time     `json:"started_at"`
+	FinishedAt          time.Time     `json:"finished_at"`
+	Duration            Duration      `json:"duration"`
+	Tables              []TableResult `json:"tables"`
+}
+
+// Count returns the number of tables that ended with status.
+func (r *ReplicationResult) Count(status TableStatus) int {
+	count := 0
+	for _, table := range r.Tables {
+		if table.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Err joins the errors of every failed table, or returns nil when none failed.
+func (r *ReplicationResult) Err() error {
+	var errs []error
+	for _, table := range r.Tables {
+		if table.Status == StatusFailed {
+			errs = append(errs, fmt.Errorf("table %s: %s", table.Table, table.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteText renders the result as a table with one line per table.
+func (r *ReplicationResult) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tSTATUS\tMODE\tROWS\tBYTES\tDURATION\tDETAIL")
+	for _, table := range r.Tables {
+		detail := table.Reason
+		if table.Error != "" {
+			detail = table.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", tableLabel(table.Table, table.Destination), table.Status, table.Mode, table.Rows, table.Bytes, time.Duration(table.Duration), detail)
+	}
+	fmt.Fprintf(tw, "\n%d created, %d copied, %d skipped, %d failed in %s\n",
+		r.Count(StatusCreated), r.Count(StatusCopied), r.Count(StatusSkipped), r.Count(StatusFailed), time.Duration(r.Duration))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return r.writeSchemaChanges(w)
+}
+
+// writeSchemaChanges lists the schema changes of every table, if any.
+func (r *ReplicationResult) writeSchemaChanges(w io.Writer) error {
+	header := false
+	for _, table := range r.Tables {
+		for _, change := range table.SchemaChanges {
+			if !header {
+				if _, err := fmt.Fprintln(w, "\nSchema changes:"); err != nil {
+					return err
+				}
+				header = true
+			}
+			outcome := "not applied"
+			if change.Applied {
+				outcome = "applied"
+			}
+			if _, err := fmt.Fprintf(w, "  %s: %s (%s)\n", tableLabel(table.Table, table.Destination), change, outcome); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// JobResult reports a run covering one or more databases.
+type JobResult struct {
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt time.Time           `json:"finished_at"`
+	Duration   Duration            `json:"duration"`
+	Databases  []ReplicationResult `json:"databases"`
+}
+
+// Count returns the number of tables of every database that ended with status.
+func (r *JobResult) Count(status TableStatus) int {
+	count := 0
+	for i := range r.Databases {
+		count += r.Databases[i].Count(status)
+	}
+	return count
+}
+
+// Err joins the errors of every failed table, or returns nil when none failed.
+func (r *JobResult) Err() error {
+	var errs []error
+	for i := range r.Databases {
+		if err := r.Databases[i].Err(); err != nil {
+			errs = append(errs, fmt.Errorf("database %s: %w", r.Databases[i].SourceDatabase, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteText renders the result of every database followed by the totals.
+func (r *JobResult) WriteText(w io.Writer) error {
+	for i := range r.Databases {
+		database := &r.Databases[i]
+		if _, err := fmt.Fprintf(w, "%s -> %s\n\n", database.SourceDatabase, database.DestinationDatabase); err != nil {
+			return err
+		}
+		if err := database.WriteText(w); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	_, err := fmt.Fprintf(w, "%d databases: %d created, %d copied, %d skipped, %d failed in %s\n", len(r.Databases),
+		r.Count(StatusCreated), r.Count(StatusCopied), r.Count(StatusSkipped), r.Count(StatusFailed), time.Duration(r.Duration))
+	return err
+}
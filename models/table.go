@@ -0,0 +1,97 @@
+package models
+
+// Column describes a column as reported by system.columns.
+type Column struct {
+	Name              string `json:"name" yaml:"name"`
+	Type              string `json:"type" yaml:"type"`
+	Position          uint64 `json:"position" yaml:"position"`
+	DefaultKind       string `json:"default_kind,omitempty" yaml:"default_kind,omitempty"`
+	DefaultExpression string `json:"default_expression,omitempty" yaml:"default_expression,omitempty"`
+	Comment           string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	CompressionCodec  string `json:"compression_codec,omitempty" yaml:"compression_codec,omitempty"`
+}
+
+// TableConfig holds per-table replication settings.
+type TableConfig struct {
+	Name string `json:"name" yaml:"name"`
+	// CursorColumn enables incremental replication: only rows with a value
+	// greater than the last copied one are transferred.
+	CursorColumn string `json:"cursor_column,omitempty" yaml:"cursor_column,omitempty"`
+	// Lookback re-copies rows this far behind the watermark to pick up late
+	// arriving data. It requires a Date or DateTime cursor column.
+	Lookback Duration `json:"lookback,omitempty" yaml:"lookback,omitempty"`
+	// Mode decides how the rows reach the destination table; empty means
+	// LoadAppend.
+	Mode LoadMode `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// LoadMode is how a run loads the rows of a table into the destination.
+type LoadMode string
+
+const (
+	// LoadAppend keeps the destination rows and copies what they lack: the
+	// rows past the watermark, or the partitions whose row count differs.
+	LoadAppend LoadMode = "append"
+	// LoadTruncateReload empties the destination table and copies every row.
+	LoadTruncateReload LoadMode = "truncate-and-reload"
+	// LoadFullRefreshSwap copies every row into a staging table and swaps it
+	// with the destination table using EXCHANGE TABLES, so readers never see
+	// a partly loaded table. The destination database must be Atomic.
+	LoadFullRefreshSwap LoadMode = "full-refresh-swap"
+	// LoadUpsert inserts the rows into a ReplacingMergeTree table, which
+	// replaces older versions of a row when its parts are merged.
+	LoadUpsert LoadMode = "upsert"
+)
+
+// Incremental reports whether the mode can copy only the rows past a cursor
+// column's watermark.
+func (m LoadMode) Incremental() bool {
+	return m == "" || m == LoadAppend || m == LoadUpsert
+}
+
+// ObjectKind is the kind of object a row of system.tables describes.
+type ObjectKind string
+
+const (
+	KindTable            ObjectKind = "table"
+	KindView             ObjectKind = "view"
+	KindMaterializedView ObjectKind = "materialized-view"
+	KindDictionary       ObjectKind = "dictionary"
+)
+
+// DatabaseObject is a table, view or dictionary of a database.
+type DatabaseObject struct {
+	Name   string `json:"name" yaml:"name"`
+	Engine string `json:"engine" yaml:"engine"`
+	// Dependents lists the objects of the same database that read from this
+	// one, such as the materialized views selecting from a table.
+	Dependents []string `json:"dependents,omitempty" yaml:"dependents,omitempty"`
+}
+
+// TableSchema holds the table level schema reported by system.tables.
+type TableSchema struct {
+	Engine       string `json:"engine" yaml:"engine"`
+	PartitionKey string `json:"partition_key,omitempty" yaml:"partition_key,omitempty"`
+	SortingKey   string `json:"sorting_key,omitempty" yaml:"sorting_key,omitempty"`
+	PrimaryKey   string `json:"primary_key,omitempty" yaml:"primary_key,omitempty"`
+	// TTL is the table TTL clause without the TTL keyword.
+	TTL string `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+}
+
+// Partition summarises the active parts of one table partition.
+type Partition struct {
+	ID    string `json:"id" yaml:"id"`
+	Name  string `json:"name" yaml:"name"`
+	Rows  uint64 `json:"rows" yaml:"rows"`
+	Bytes uint64 `json:"bytes" yaml:"bytes"`
+}
+
+// Chunk is a range of a table's sorting key. Chunks of one plan do not
+// overlap, so they can be read in parallel and re-read without duplicates.
+type Chunk struct {
+	ID string `json:"id" yaml:"id"`
+	// Condition selects the rows of the chunk; it is empty for a whole table.
+	Condition string `json:"condition" yaml:"condition"`
+	Lower     string `json:"lower,omitempty" yaml:"lower,omitempty"`
+	Upper     string `json:"upper,omitempty" yaml:"upper,omitempty"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Checksum is the row count and order independent content hash of a set of rows.
+type Checksum struct {
+	Rows uint64 `json:"rows"`
+	Hash uint64 `json:"hash"`
+}
+
+// PartitionVerification compares one partition of a table on both servers.
+type PartitionVerification struct {
+	Partition   string   `json:"partition"`
+	Source      Checksum `json:"source"`
+	Destination Checksum `json:"destination"`
+	Match       bool     `json:"match"`
+}
+
+// TableVerification compares one table on both servers.
+type TableVerification struct {
+	Table       string                  `json:"table"`
+	Destination string                  `json:"destination"`
+	Match       bool                    `json:"match"`
+	Error       string                  `json:"error,omitempty"`
+	Partitions  []PartitionVerification `json:"partitions"`
+}
+
+// VerificationReport is the outcome of comparing the source and destination.
+type VerificationReport struct {
+	Match  bool                `json:"match"`
+	Tables []TableVerification `json:"tables"`
+}
+
+// WriteText renders the report as a table with one line per partition.
+func (r *VerificationReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tPARTITION\tSOURCE ROWS\tDESTINATION ROWS\tSOURCE HASH\tDESTINATION HASH\tSTATUS")
+	for _, table := range r.Tables {
+		if table.Error != "" {
+			fmt.Fprintf(tw, "%s\t\t\t\t\t\terror: %s\n", tableLabel(table.Table, table.Destination), table.Error)
+			continue
+		}
+		for _, partition := range table.Partitions {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%016x\t%016x\t%s\n", tableLabel(table.Table, table.Destination), partition.Partition,
+				partition.Source.Rows, partition.Destination.Rows, partition.Source.Hash, partition.Destination.Hash, matchStatus(partition.Match))
+		}
+	}
+	status := "all tables match"
+	if !r.Match {
+		status = "MISMATCH"
+	}
+	fmt.Fprintf(tw, "\n%d tables verified: %s\n", len(r.Tables), status)
+	return tw.Flush()
+}
+
+func matchStatus(match bool) string {
+	if match {
+		return "ok"
+	}
+	return "MISMATCH"
+}
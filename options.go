@@ -0,0 +1,288 @@
+package clickreplicator
+
+import (
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/checkpoint"
+	"github.com/prasannakumar414/click-replicator/services/replicator"
+	"go.uber.org/zap"
+)
+
+// Option configures a ClickReplicator.
+type Option func(*ClickReplicator)
+
+// WithLogger sets the logger used by every component. By default a
+// zap production logger is created for each call.
+func WithLogger(logger *zap.Logger) Option {
+	return func(f *ClickReplicator) {
+		f.logger = logger
+	}
+}
+
+// WithSource replaces the connection to the source configuration with an
+// existing data source. The native transfer and several databases per job
+// need a *clickhouse.ClickhouseService.
+func WithSource(source replicator.DataSource) Option {
+	return func(f *ClickReplicator) {
+		f.source = source
+	}
+}
+
+// WithDestination replaces the connection to the destination configuration
+// with an existing data source. The native transfer, several databases per
+// job, WithCheckpointTable and WithDestinationCluster need a
+// *clickhouse.ClickhouseService.
+func WithDestination(destination replicator.DataSource) Option {
+	return func(f *ClickReplicator) {
+		f.destination = destination
+	}
+}
+
+// WithGenerator replaces the generator chosen by the transfer method.
+func WithGenerator(generator replicator.Generator) Option {
+	return func(f *ClickReplicator) {
+		f.generator = generator
+	}
+}
+
+// WithInserter replaces the inserter chosen by the transfer method.
+func WithInserter(inserter replicator.Inserter) Option {
+	return func(f *ClickReplicator) {
+		f.inserter = inserter
+	}
+}
+
+// WithStagingDir sets the directory where the clickhouse-client transfer
+// writes its JSONEachRow files. It defaults to the working directory.
+func WithStagingDir(dir string) Option {
+	return func(f *ClickReplicator) {
+		f.stagingDir = dir
+	}
+}
+
+// WithTransferMethod selects how rows are copied. The default is
+// models.TransferNative; models.TransferClient keeps the clickhouse-client path.
+func WithTransferMethod(method models.TransferMethod) Option {
+	return func(f *ClickReplicator) {
+		f.transferMethod = method
+	}
+}
+
+// WithTables sets per-table replication settings such as the cursor column
+// used for incremental copies.
+func WithTables(tables ...models.TableConfig) Option {
+	return func(f *ClickReplicator) {
+		f.tables = append(f.tables, tables...)
+	}
+}
+
+// WithWatermarkFile sets the file where incremental watermarks are kept.
+// It defaults to watermark.DefaultFileName in the working directory.
+func WithWatermarkFile(path string) Option {
+	return func(f *ClickReplicator) {
+		f.watermarkFile = path
+	}
+}
+
+// WithCheckpointFile keeps run checkpoints in a local JSON file. It defaults to
+// checkpoint.DefaultFileName in the working directory.
+func WithCheckpointFile(path string) Option {
+	return func(f *ClickReplicator) {
+		f.checkpointFile = path
+		f.checkpointTable = ""
+	}
+}
+
+// WithCheckpointTable keeps run checkpoints in a metadata table on the
+// destination server instead of a local file. An empty database selects the
+// destination database and an empty table name checkpoint.DefaultTableName.
+func WithCheckpointTable(database string, table string) Option {
+	return func(f *ClickReplicator) {
+		f.checkpointDatabase = database
+		f.checkpointTable = table
+		if table == "" {
+			f.checkpointTable = checkpoint.DefaultTableName
+		}
+	}
+}
+
+// WithRestart discards the checkpoints of an interrupted run so every table is
+// copied from scratch.
+func WithRestart() Option {
+	return func(f *ClickReplicator) {
+		f.restart = true
+	}
+}
+
+// WithConcurrency replicates up to n tables in parallel.
+func WithConcurrency(n int) Option {
+	return func(f *ClickReplicator) {
+		f.concurrency = n
+	}
+}
+
+// WithTableOrder decides whether the largest or the smallest tables start first.
+func WithTableOrder(order models.TableOrder) Option {
+	return func(f *ClickReplicator) {
+		f.order = order
+	}
+}
+
+// WithMaxSourceQueries caps how many tables are read from the source at the
+// same time, regardless of the concurrency.
+func WithMaxSourceQueries(n int) Option {
+	return func(f *ClickReplicator) {
+		f.maxSourceQueries = n
+	}
+}
+
+// WithRetries gives each failed partition, chunk or copy of an unpartitioned
+// table n more attempts before the table is reported as failed.
+func WithRetries(n int) Option {
+	return func(f *ClickReplicator) {
+		f.retries = n
+	}
+}
+
+// WithChunkRows splits tables and partitions larger than rows into sorting key
+// ranges of about that many rows, each copied and checkpointed on its own.
+func WithChunkRows(rows uint64) Option {
+	return func(f *ClickReplicator) {
+		f.chunkRows = rows
+	}
+}
+
+// WithStrict makes ReplicateDatabase return an error joining the errors of
+// every failed table. Without it failures are only reported in the result.
+func WithStrict() Option {
+	return func(f *ClickReplicator) {
+		f.strict = true
+	}
+}
+
+// WithIncludeTables limits the run to the tables matching any of patterns.
+// Patterns are globs such as "events_*", or regular expressions matching the
+// whole name when prefixed with "re:".
+func WithIncludeTables(patterns ...string) Option {
+	return func(f *ClickReplicator) {
+		f.includeTables = append(f.includeTables, patterns...)
+	}
+}
+
+// WithExcludeTables leaves out the tables matching any of patterns, in the
+// same syntax as WithIncludeTables. Exclusions win over inclusions.
+func WithExcludeTables(patterns ...string) Option {
+	return func(f *ClickReplicator) {
+		f.excludeTables = append(f.excludeTables, patterns...)
+	}
+}
+
+// WithTableMapping renames tables on the destination with explicit pairs,
+// regex rewrites and a {table} template. The destination database is the one
+// of the destination config.
+func WithTableMapping(mapping models.TableMapping) Option {
+	return func(f *ClickReplicator) {
+		f.tableMapping = mapping
+	}
+}
+
+// WithDatabases makes ReplicateDatabases copy the given databases, each to
+// its destination database or to one of the same name.
+func WithDatabases(databases ...models.DatabaseMapping) Option {
+	return func(f *ClickReplicator) {
+		f.databases = append(f.databases, databases...)
+	}
+}
+
+// WithAllDatabases makes ReplicateDatabases copy every non-system database of
+// the source. Mappings given with WithDatabases still choose the destination
+// of the databases they name.
+func WithAllDatabases() Option {
+	return func(f *ClickReplicator) {
+		f.allDatabases = true
+	}
+}
+
+// WithSchemaPolicy decides what a run does when the columns of a source table
+// were added, dropped, renamed or retyped since its destination table was
+// created: warn (the default), apply the matching ALTERs or fail the table.
+func WithSchemaPolicy(policy models.SchemaPolicy) Option {
+	return func(f *ClickReplicator) {
+		f.schemaPolicy = policy
+	}
+}
+
+// WithEngineTranslation rewrites the engine of cloned tables with the rules
+// of presets such as "replicated-to-plain" followed by its own rules, for
+// copies between a replicated cluster and a single node or between clusters.
+func WithEngineTranslation(translation models.EngineTranslation) Option {
+	return func(f *ClickReplicator) {
+		f.engineTranslation = translation
+	}
+}
+
+// WithEnginePresets adds built-in engine translation presets. Their rules
+// run before the rules given with WithEngineTranslation.
+func WithEnginePresets(presets ...string) Option {
+	return func(f *ClickReplicator) {
+		f.engineTranslation.Presets = append(f.engineTranslation.Presets, presets...)
+	}
+}
+
+// WithDestinationCluster runs the destination DDL ON CLUSTER and checks that
+// every host finished it, and optionally creates a Distributed table over
+// every table. A destination given with WithDestination must be a
+// *clickhouse.ClickhouseService; it takes the cluster unless it has its own.
+func WithDestinationCluster(cluster models.ClusterConfig) Option {
+	return func(f *ClickReplicator) {
+		f.destinationCluster = cluster
+	}
+}
+
+// JobOptions turns a job config into options. Settings the job leaves at
+// their zero value keep the defaults.
+func JobOptions(job *models.JobConfig) []Option {
+	opts := []Option{
+		WithIncludeTables(job.Include...),
+		WithExcludeTables(job.Exclude...),
+		WithTableMapping(job.Mapping),
+		WithDatabases(job.Databases...),
+		WithTables(job.Tables...),
+		WithConcurrency(job.Concurrency),
+		WithTableOrder(job.Order),
+		WithMaxSourceQueries(job.MaxSourceQueries),
+		WithChunkRows(job.ChunkRows),
+		WithEngineTranslation(job.Engines),
+		WithDestinationCluster(job.DestinationCluster),
+	}
+	if job.AllDatabases {
+		opts = append(opts, WithAllDatabases())
+	}
+	if job.Transfer != "" {
+		opts = append(opts, WithTransferMethod(job.Transfer))
+	}
+	if job.StagingDir != "" {
+		opts = append(opts, WithStagingDir(job.StagingDir))
+	}
+	if job.Retries != nil {
+		opts = append(opts, WithRetries(*job.Retries))
+	}
+	if job.Strict {
+		opts = append(opts, WithStrict())
+	}
+	if job.SchemaPolicy != "" {
+		opts = append(opts, WithSchemaPolicy(job.SchemaPolicy))
+	}
+	if job.Restart {
+		opts = append(opts, WithRestart())
+	}
+	if job.Watermarks != "" {
+		opts = append(opts, WithWatermarkFile(job.Watermarks))
+	}
+	if job.Checkpoints.File != "" {
+		opts = append(opts, WithCheckpointFile(job.Checkpoints.File))
+	}
+	if job.Checkpoints.Table != "" {
+		opts = append(opts, WithCheckpointTable(job.Checkpoints.Database, job.Checkpoints.Table))
+	}
+	return opts
+}
@@ -0,0 +1,104 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/utils"
+)
+
+// DefaultFileName is used when no checkpoint location is configured.
+const DefaultFileName = "click_replicator_checkpoints.json"
+
+// FileStore keeps checkpoints in a local JSON file, keyed by table and chunk.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+func (s *FileStore) Status(ctx context.Context, table string, chunk string) (models.CheckpointStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return models.CheckpointNone, err
+	}
+	return checkpoints[table][chunk], nil
+}
+
+func (s *FileStore) SetStatus(ctx context.Context, table string, chunk string, status models.CheckpointStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+	if checkpoints[table] == nil {
+		checkpoints[table] = make(map[string]models.CheckpointStatus)
+	}
+	checkpoints[table][chunk] = status
+	return s.write(checkpoints)
+}
+
+// ResetTable discards the checkpoints of table.
+func (s *FileStore) ResetTable(ctx context.Context, table string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := checkpoints[table]; !ok {
+		return nil
+	}
+	delete(checkpoints, table)
+	return s.write(checkpoints)
+}
+
+// Reset discards every checkpoint.
+func (s *FileStore) Reset(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) read() (map[string]map[string]models.CheckpointStatus, error) {
+	checkpoints := make(map[string]map[string]models.CheckpointStatus)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+func (s *FileStore) write(checkpoints map[string]map[string]models.CheckpointStatus) error {
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(s.path, data)
+}
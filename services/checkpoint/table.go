@@ -0,0 +1,84 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+// DefaultTableName is the metadata table used by TableStore when none is given.
+const DefaultTableName = "click_replicator_checkpoints"
+
+// TableStore keeps checkpoints in a ReplacingMergeTree table, usually on the
+// destination server, so any host can resume a run.
+type TableStore struct {
+	conn     driver.Conn
+	database string
+	table    string
+	once     sync.Once
+	initErr  error
+}
+
+func NewTableStore(conn driver.Conn, database string, table string) *TableStore {
+	if table == "" {
+		table = DefaultTableName
+	}
+	return &TableStore{
+		conn:     conn,
+		database: database,
+		table:    table,
+	}
+}
+
+func (s *TableStore) Status(ctx context.Context, table string, chunk string) (models.CheckpointStatus, error) {
+	if err := s.init(ctx); err != nil {
+		return models.CheckpointNone, err
+	}
+	query := fmt.Sprintf("SELECT argMax(status, updated_at) FROM %s.%s WHERE table_name = ? AND chunk = ?", s.database, s.table)
+
+	var status string
+	if err := s.conn.QueryRow(ctx, query, table, chunk).Scan(&status); err != nil {
+		return models.CheckpointNone, err
+	}
+	return models.CheckpointStatus(status), nil
+}
+
+func (s *TableStore) SetStatus(ctx context.Context, table string, chunk string, status models.CheckpointStatus) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+	query := fmt.Sprintf("INSERT INTO %s.%s (table_name, chunk, status, updated_at) VALUES (?, ?, ?, now64(3))", s.database, s.table)
+	return s.conn.Exec(ctx, query, table, chunk, string(status))
+}
+
+// ResetTable discards the checkpoints of table and waits for the deletion.
+func (s *TableStore) ResetTable(ctx context.Context, table string) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+	query := fmt.Sprintf("ALTER TABLE %s.%s DELETE WHERE table_name = ? SETTINGS mutations_sync = 2", s.database, s.table)
+	return s.conn.Exec(ctx, query, table)
+}
+
+// Reset discards every checkpoint.
+func (s *TableStore) Reset(ctx context.Context) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+	return s.conn.Exec(ctx, fmt.Sprintf("TRUNCATE TABLE %s.%s", s.database, s.table))
+}
+
+func (s *TableStore) init(ctx context.Context) error {
+	s.once.Do(func() {
+		query := "CREATE DATABASE IF NOT EXISTS %s"
+		if s.initErr = s.conn.Exec(ctx, fmt.Sprintf(query, s.database)); s.initErr != nil {
+			return
+		}
+		query = "CREATE TABLE IF NOT EXISTS %s.%s (table_name String, chunk String, status LowCardinality(String), updated_at DateTime64(3)) ENGINE = ReplacingMergeTree(updated_at) ORDER BY (table_name, chunk)"
+		s.initErr = s.conn.Exec(ctx, fmt.Sprintf(query, s.database, s.table))
+	})
+	return s.initErr
+}
@@ -0,0 +1,250 @@
+// Package dump exports tables to a directory of JSONEachRow files and imports
+// them back, so a database can be moved where no direct connection exists.
+package dump
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/datasources/clickhouse"
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// DataExtension is the extension of the files holding the rows of a table.
+	DataExtension = ".jsonl"
+	// SchemaExtension is the extension of the files holding the create query of a table.
+	SchemaExtension = ".sql"
+	// Format is the row format of the data files.
+	Format = "JSONEachRow"
+	// DefaultBatchRows is the number of rows inserted at once on import.
+	DefaultBatchRows = 10000
+)
+
+// Source is what the Exporter reads tables from.
+type Source interface {
+	Database() string
+	GetCreateTableQuery(ctx context.Context, tableName string) (string, error)
+	ExportRows(ctx context.Context, tableName string, format string, w io.Writer) (uint64, error)
+}
+
+// Destination is what the Importer writes tables to.
+type Destination interface {
+	Database() string
+	IsTableExists(ctx context.Context, tableName string) (bool, error)
+	ExecuteDDL(ctx context.Context, query string) error
+	InsertRows(ctx context.Context, tableName string, format string, rows []string) error
+}
+
+// Exporter writes each table to <dir>/<table>.jsonl next to its create query
+// in <dir>/<table>.sql.
+type Exporter struct {
+	logger *zap.Logger
+	source Source
+	dir    string
+}
+
+func NewExporter(logger *zap.Logger, source Source, dir string) *Exporter {
+	return &Exporter{logger: logger, source: source, dir: dir}
+}
+
+// Export writes every table in tables. A failing table does not stop the
+// others; it is reported with its error. Tables not started when ctx is
+// cancelled are left out of the result.
+func (e *Exporter) Export(ctx context.Context, tables []string) (*models.DumpResult, error) {
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return nil, err
+	}
+	result := &models.DumpResult{Directory: e.dir}
+	for _, table := range tables {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		exported := e.exportTable(ctx, table)
+		if exported.Error != "" {
+			e.logger.Error("Error exporting table", zap.String("table", table), zap.String("error", exported.Error))
+		} else {
+			e.logger.Info("Exported table", zap.String("table", table), zap.Uint64("rows", exported.Rows))
+		}
+		result.Tables = append(result.Tables, exported)
+	}
+	return result, ctx.Err()
+}
+
+func (e *Exporter) exportTable(ctx context.Context, table string) models.DumpTable {
+	exported := models.DumpTable{Table: table, File: filepath.Join(e.dir, table+DataExtension)}
+	query, err := e.source.GetCreateTableQuery(ctx, table)
+	if err != nil {
+		exported.Error = fmt.Sprintf("fetching create query: %v", err)
+		return exported
+	}
+	if err := os.WriteFile(filepath.Join(e.dir, table+SchemaExtension), []byte(query+"\n"), 0o644); err != nil {
+		exported.Error = err.Error()
+		return exported
+	}
+
+	// Rows go to a temporary file first so an interrupted export never leaves
+	// a truncated data file behind.
+	temporary := exported.File + ".tmp"
+	file, err := os.Create(temporary)
+	if err != nil {
+		exported.Error = err.Error()
+		return exported
+	}
+	writer := bufio.NewWriter(file)
+	exported.Rows, err = e.source.ExportRows(ctx, table, Format, writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporary, exported.File)
+	}
+	if err != nil {
+		os.Remove(temporary)
+		exported.Error = err.Error()
+	}
+	return exported
+}
+
+// Importer loads the files written by an Exporter into the destination,
+// creating missing tables from their create query. Rows are appended, so
+// importing the same files twice duplicates them.
+type Importer struct {
+	logger      *zap.Logger
+	destination Destination
+	dir         string
+	batchRows   int
+	rename      func(string) string
+}
+
+// NewImporter returns an importer inserting batchRows rows at a time, or
+// DefaultBatchRows when batchRows is not positive. rename gives the
+// destination name of a table; nil keeps the names.
+func NewImporter(logger *zap.Logger, destination Destination, dir string, batchRows int, rename func(string) string) *Importer {
+	if batchRows <= 0 {
+		batchRows = DefaultBatchRows
+	}
+	if rename == nil {
+		rename = func(table string) string { return table }
+	}
+	return &Importer{logger: logger, destination: destination, dir: dir, batchRows: batchRows, rename: rename}
+}
+
+// Tables lists the tables with a data file in the directory, sorted by name.
+func (i *Importer) Tables() ([]string, error) {
+	entries, err := os.ReadDir(i.dir)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), DataExtension); ok && !entry.IsDir() {
+			tables = append(tables, name)
+		}
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// Import loads every table in tables. A failing table does not stop the
+// others; it is reported with its error.
+func (i *Importer) Import(ctx context.Context, tables []string) (*models.DumpResult, error) {
+	result := &models.DumpResult{Directory: i.dir}
+	for _, table := range tables {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		imported := i.importTable(ctx, table)
+		if imported.Error != "" {
+			i.logger.Error("Error importing table", zap.String("table", table), zap.String("error", imported.Error))
+		} else {
+			i.logger.Info("Imported table", zap.String("table", table), zap.String("destination", imported.Destination), zap.Uint64("rows", imported.Rows))
+		}
+		result.Tables = append(result.Tables, imported)
+	}
+	return result, ctx.Err()
+}
+
+func (i *Importer) importTable(ctx context.Context, table string) models.DumpTable {
+	imported := models.DumpTable{Table: table, Destination: i.rename(table), File: filepath.Join(i.dir, table+DataExtension)}
+	if err := i.createTable(ctx, table, imported.Destination); err != nil {
+		imported.Error = err.Error()
+		return imported
+	}
+
+	file, err := os.Open(imported.File)
+	if err != nil {
+		imported.Error = err.Error()
+		return imported
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	batch := make([]string, 0, i.batchRows)
+	flush := func() error {
+		if err := i.destination.InsertRows(ctx, imported.Destination, Format, batch); err != nil {
+			return fmt.Errorf("inserting rows %d to %d: %w", imported.Rows+1, imported.Rows+uint64(len(batch)), err)
+		}
+		imported.Rows += uint64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		batch = append(batch, scanner.Text())
+		if len(batch) == i.batchRows {
+			if err := flush(); err != nil {
+				imported.Error = err.Error()
+				return imported
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		imported.Error = err.Error()
+		return imported
+	}
+	if err := flush(); err != nil {
+		imported.Error = err.Error()
+	}
+	return imported
+}
+
+// createTable creates destinationTable from the schema file of table unless
+// it already exists.
+func (i *Importer) createTable(ctx context.Context, table string, destinationTable string) error {
+	exists, err := i.destination.IsTableExists(ctx, destinationTable)
+	if err != nil {
+		return fmt.Errorf("checking if table exists: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	query, err := os.ReadFile(filepath.Join(i.dir, table+SchemaExtension))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("table %s does not exist and there is no %s%s to create it from", destinationTable, table, SchemaExtension)
+	}
+	if err != nil {
+		return err
+	}
+	ddl, err := clickhouse.RewriteCreateQuery(strings.TrimSpace(string(query)), i.destination.Database(), destinationTable)
+	if err != nil {
+		return err
+	}
+	i.logger.Info("Creating table", zap.String("table", destinationTable), zap.String("query", ddl))
+	return i.destination.ExecuteDDL(ctx, ddl)
+}
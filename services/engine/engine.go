@@ -0,0 +1,192 @@
+// Package engine rewrites the ENGINE clause of create queries for copies
+// between servers of different topologies.
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+const (
+	// PresetReplicatedToPlain copies a replicated cluster to a single node.
+	PresetReplicatedToPlain = "replicated-to-plain"
+	// PresetPlainToReplicated copies a single node to a replicated cluster.
+	PresetPlainToReplicated = "plain-to-replicated"
+)
+
+const (
+	// DefaultZooKeeperPath is the path the replicate rule gives tables when
+	// the rule has none.
+	DefaultZooKeeperPath = "/clickhouse/tables/{shard}/{database}/{table}"
+	// DefaultReplica is the replica name the replicate rule gives tables when
+	// the rule has none.
+	DefaultReplica = "{replica}"
+)
+
+// Presets are the built-in rule sets that an EngineTranslation names.
+var Presets = map[string][]models.EngineRule{
+	PresetReplicatedToPlain: {{Rule: models.RuleUnreplicate}},
+	PresetPlainToReplicated: {{Rule: models.RuleReplicate}},
+}
+
+// Translator applies a models.EngineTranslation. A nil Translator keeps every
+// query unchanged.
+type Translator struct {
+	rules  []models.EngineRule
+	macros map[string]string
+}
+
+// New checks translation and returns its Translator, or nil when it has no rules.
+func New(translation models.EngineTranslation) (*Translator, error) {
+	t := &Translator{macros: translation.Macros}
+	for _, name := range translation.Presets {
+		rules, ok := Presets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown engine preset %q, expected %s", name, presetNames())
+		}
+		t.rules = append(t.rules, rules...)
+	}
+	for i, rule := range translation.Rules {
+		switch rule.Rule {
+		case models.RuleUnreplicate, models.RuleReplicate:
+		case models.RuleZooKeeperPath:
+			if rule.ZooKeeperPath == "" {
+				return nil, fmt.Errorf("rule %d: %s requires zookeeper_path", i+1, rule.Rule)
+			}
+		case models.RuleDistributedCluster:
+			if rule.To == "" {
+				return nil, fmt.Errorf("rule %d: %s requires to", i+1, rule.Rule)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unknown rule %q, expected %q, %q, %q or %q", i+1, rule.Rule,
+				models.RuleUnreplicate, models.RuleReplicate, models.RuleZooKeeperPath, models.RuleDistributedCluster)
+		}
+		t.rules = append(t.rules, rule)
+	}
+	for name := range translation.Macros {
+		if name == "" || strings.ContainsAny(name, "{}") {
+			return nil, fmt.Errorf("invalid macro name %q", name)
+		}
+	}
+	if len(t.rules) == 0 && len(t.macros) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+func presetNames() string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Translate rewrites the ENGINE clause of a create query with the rules in
+// order. Queries without an ENGINE clause, such as views, are returned as is.
+func (t *Translator) Translate(query string) (string, error) {
+	if t == nil {
+		return query, nil
+	}
+	clause, ok := Parse(query)
+	if !ok {
+		return query, nil
+	}
+	engine := clause
+	for _, rule := range t.rules {
+		if err := apply(&engine, rule); err != nil {
+			return "", fmt.Errorf("engine %s: %w", clause.Name, err)
+		}
+	}
+	if replicated(engine.Name) {
+		for i := 0; i < 2 && i < len(engine.Args); i++ {
+			if value, ok := unquote(engine.Args[i]); ok {
+				engine.Args[i] = quote(t.expand(value))
+			}
+		}
+	}
+	return query[:clause.start] + engine.String() + query[clause.end:], nil
+}
+
+// expand replaces the macros of the translation in value.
+func (t *Translator) expand(value string) string {
+	for name, replacement := range t.macros {
+		value = strings.ReplaceAll(value, "{"+name+"}", replacement)
+	}
+	return value
+}
+
+func apply(engine *Engine, rule models.EngineRule) error {
+	switch rule.Rule {
+	case models.RuleUnreplicate:
+		if !replicated(engine.Name) {
+			return nil
+		}
+		engine.Name = strings.TrimPrefix(engine.Name, "Replicated")
+		if hasReplicationArgs(engine.Args) {
+			engine.Args = engine.Args[2:]
+		}
+	case models.RuleReplicate:
+		if !strings.HasSuffix(engine.Name, "MergeTree") || replicated(engine.Name) {
+			return nil
+		}
+		path, replica := rule.ZooKeeperPath, rule.Replica
+		if path == "" {
+			path = DefaultZooKeeperPath
+		}
+		if replica == "" {
+			replica = DefaultReplica
+		}
+		engine.Name = "Replicated" + engine.Name
+		engine.Args = append([]string{quote(path), quote(replica)}, engine.Args...)
+	case models.RuleZooKeeperPath:
+		if !replicated(engine.Name) {
+			return nil
+		}
+		if !hasReplicationArgs(engine.Args) {
+			// The engine relied on the server's default path and replica.
+			replica := rule.Replica
+			if replica == "" {
+				replica = DefaultReplica
+			}
+			engine.Args = append([]string{quote(rule.ZooKeeperPath), quote(replica)}, engine.Args...)
+			break
+		}
+		engine.Args[0] = quote(rule.ZooKeeperPath)
+		if rule.Replica != "" {
+			engine.Args[1] = quote(rule.Replica)
+		}
+	case models.RuleDistributedCluster:
+		if engine.Name != "Distributed" {
+			return nil
+		}
+		if len(engine.Args) < 3 {
+			return fmt.Errorf("expected cluster, database and table arguments, got %d", len(engine.Args))
+		}
+		if rule.From == "" || rule.From == engine.Cluster() {
+			engine.Args[0] = quote(rule.To)
+		}
+	}
+	engine.parens = engine.parens || len(engine.Args) > 0
+	return nil
+}
+
+// replicated tells whether name is a replicated MergeTree family engine.
+func replicated(name string) bool {
+	return strings.HasPrefix(name, "Replicated") && strings.HasSuffix(name, "MergeTree")
+}
+
+// hasReplicationArgs tells whether args start with a ZooKeeper path and a
+// replica name rather than the engine's own parameters.
+func hasReplicationArgs(args []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+	_, path := unquote(args[0])
+	_, replica := unquote(args[1])
+	return path && replica
+}
@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		want   Engine
+		wantOK bool
+		clause string
+	}{
+		{
+			name:   "no arguments",
+			query:  "CREATE TABLE db.t (id UInt64) ENGINE = Log",
+			want:   Engine{Name: "Log"},
+			wantOK: true,
+			clause: "Log",
+		},
+		{
+			name:   "empty argument list",
+			query:  "CREATE TABLE db.t (id UInt64) ENGINE = MergeTree() ORDER BY id",
+			want:   Engine{Name: "MergeTree", parens: true},
+			wantOK: true,
+			clause: "MergeTree()",
+		},
+		{
+			name:   "nested parentheses",
+			query:  "CREATE TABLE db.t (id UInt64, v UInt64) ENGINE = ReplacingMergeTree(greatest(v, toUInt64(1))) ORDER BY id",
+			want:   Engine{Name: "ReplacingMergeTree", Args: []string{"greatest(v, toUInt64(1))"}, parens: true},
+			wantOK: true,
+			clause: "ReplacingMergeTree(greatest(v, toUInt64(1)))",
+		},
+		{
+			name:   "quoted commas and parentheses",
+			query:  `CREATE TABLE db.t (id UInt64) ENGINE = ReplicatedMergeTree('/tables/a,(b)\'', '{replica}') ORDER BY id`,
+			want:   Engine{Name: "ReplicatedMergeTree", Args: []string{`'/tables/a,(b)\''`, "'{replica}'"}, parens: true},
+			wantOK: true,
+			clause: `ReplicatedMergeTree('/tables/a,(b)\'', '{replica}')`,
+		},
+		{
+			name:   "settings after the engine",
+			query:  "CREATE TABLE db.t (id UInt64) ENGINE = MergeTree ORDER BY id SETTINGS index_granularity = 8192, storage_policy = 'engine = x'",
+			want:   Engine{Name: "MergeTree"},
+			wantOK: true,
+			clause: "MergeTree",
+		},
+		{
+			name:   "engine in a column default is ignored",
+			query:  "CREATE TABLE db.t (`ENGINE` String DEFAULT 'ENGINE = Log') ENGINE = Memory",
+			want:   Engine{Name: "Memory"},
+			wantOK: true,
+			clause: "Memory",
+		},
+		{
+			name:   "lower case keyword without spaces",
+			query:  "CREATE TABLE db.t (id UInt64) engine=TinyLog",
+			want:   Engine{Name: "TinyLog"},
+			wantOK: true,
+			clause: "TinyLog",
+		},
+		{
+			name:  "view",
+			query: "CREATE VIEW db.v AS SELECT * FROM db.t WHERE engine = 'x'",
+		},
+		{
+			name:  "unterminated arguments",
+			query: "CREATE TABLE db.t (id UInt64) ENGINE = MergeTree(",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := Parse(test.query)
+			if ok != test.wantOK {
+				t.Fatalf("Parse() ok = %v, want %v", ok, test.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.Name != test.want.Name || !reflect.DeepEqual(got.Args, test.want.Args) || got.parens != test.want.parens {
+				t.Errorf("Parse() = %+v, want %+v", got, test.want)
+			}
+			if clause := test.query[got.start:got.end]; clause != test.clause {
+				t.Errorf("Parse() spans %q, want %q", clause, test.clause)
+			}
+			if got.String() != test.clause {
+				t.Errorf("String() = %q, want %q", got.String(), test.clause)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	const (
+		columns  = "CREATE TABLE db.events (id UInt64, v UInt64) ENGINE = "
+		settings = " ORDER BY id SETTINGS index_granularity = 8192"
+	)
+	tests := []struct {
+		name        string
+		translation models.EngineTranslation
+		query       string
+		want        string
+	}{
+		{
+			name:        "replicated to plain",
+			translation: models.EngineTranslation{Presets: []string{PresetReplicatedToPlain}},
+			query:       columns + "ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/db/events', '{replica}', v)" + settings,
+			want:        columns + "ReplacingMergeTree(v)" + settings,
+		},
+		{
+			name:        "replicated to plain without arguments",
+			translation: models.EngineTranslation{Presets: []string{PresetReplicatedToPlain}},
+			query:       columns + "ReplicatedMergeTree" + settings,
+			want:        columns + "MergeTree" + settings,
+		},
+		{
+			name:        "replicated to plain keeps engine parameters",
+			translation: models.EngineTranslation{Presets: []string{PresetReplicatedToPlain}},
+			query:       columns + "ReplicatedVersionedCollapsingMergeTree(sign, v)" + settings,
+			want:        columns + "VersionedCollapsingMergeTree(sign, v)" + settings,
+		},
+		{
+			name:        "replicated to plain leaves other engines",
+			translation: models.EngineTranslation{Presets: []string{PresetReplicatedToPlain}},
+			query:       columns + "Memory",
+			want:        columns + "Memory",
+		},
+		{
+			name:        "plain to replicated",
+			translation: models.EngineTranslation{Presets: []string{PresetPlainToReplicated}},
+			query:       columns + "ReplacingMergeTree(v)" + settings,
+			want:        columns + "ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}', v)" + settings,
+		},
+		{
+			name:        "plain to replicated adds an argument list",
+			translation: models.EngineTranslation{Presets: []string{PresetPlainToReplicated}},
+			query:       columns + "MergeTree" + settings,
+			want:        columns + "ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/{table}', '{replica}')" + settings,
+		},
+		{
+			name: "replicate with a path and replica",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{
+				{Rule: models.RuleReplicate, ZooKeeperPath: "/copies/{table}", Replica: "r1"},
+			}},
+			query: columns + "MergeTree" + settings,
+			want:  columns + "ReplicatedMergeTree('/copies/{table}', 'r1')" + settings,
+		},
+		{
+			name: "shared ZooKeeper path is moved",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{
+				{Rule: models.RuleZooKeeperPath, ZooKeeperPath: "/clickhouse/copy/{shard}/db/events"},
+			}},
+			query: columns + "ReplicatedMergeTree('/clickhouse/tables/{shard}/db/events', '{replica}')" + settings,
+			want:  columns + "ReplicatedMergeTree('/clickhouse/copy/{shard}/db/events', '{replica}')" + settings,
+		},
+		{
+			name: "ZooKeeper path and replica",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{
+				{Rule: models.RuleZooKeeperPath, ZooKeeperPath: "/copy/events", Replica: "r2"},
+			}},
+			query: columns + "ReplicatedReplacingMergeTree('/tables/events', 'r1', v)" + settings,
+			want:  columns + "ReplicatedReplacingMergeTree('/copy/events', 'r2', v)" + settings,
+		},
+		{
+			name: "ZooKeeper path of an engine on the default path",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{
+				{Rule: models.RuleZooKeeperPath, ZooKeeperPath: "/copy/events"},
+			}},
+			query: columns + "ReplicatedMergeTree" + settings,
+			want:  columns + "ReplicatedMergeTree('/copy/events', '{replica}')" + settings,
+		},
+		{
+			name: "ZooKeeper path leaves plain engines",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{
+				{Rule: models.RuleZooKeeperPath, ZooKeeperPath: "/copy/events"},
+			}},
+			query: columns + "MergeTree" + settings,
+			want:  columns + "MergeTree" + settings,
+		},
+		{
+			name: "macros",
+			translation: models.EngineTranslation{
+				Presets: []string{PresetPlainToReplicated},
+				Macros:  map[string]string{"shard": "01", "replica": "node-1"},
+			},
+			query: columns + "MergeTree" + settings,
+			want:  columns + "ReplicatedMergeTree('/clickhouse/tables/01/{database}/{table}', 'node-1')" + settings,
+		},
+		{
+			name: "distributed cluster",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{
+				{Rule: models.RuleDistributedCluster, From: "source", To: "target"},
+			}},
+			query: "CREATE TABLE db.events_all AS db.events ENGINE = Distributed('source', 'db', 'events', rand())",
+			want:  "CREATE TABLE db.events_all AS db.events ENGINE = Distributed('target', 'db', 'events', rand())",
+		},
+		{
+			name: "distributed cluster from another cluster",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{
+				{Rule: models.RuleDistributedCluster, From: "other", To: "target"},
+			}},
+			query: "CREATE TABLE db.events_all AS db.events ENGINE = Distributed(source, db, events)",
+			want:  "CREATE TABLE db.events_all AS db.events ENGINE = Distributed(source, db, events)",
+		},
+		{
+			name:        "view",
+			translation: models.EngineTranslation{Presets: []string{PresetReplicatedToPlain}},
+			query:       "CREATE VIEW db.v AS SELECT * FROM db.events",
+			want:        "CREATE VIEW db.v AS SELECT * FROM db.events",
+		},
+		{
+			name:  "no translation",
+			query: columns + "ReplicatedMergeTree('/tables/events', 'r1')" + settings,
+			want:  columns + "ReplicatedMergeTree('/tables/events', 'r1')" + settings,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			translator, err := New(test.translation)
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			got, err := translator.Translate(test.query)
+			if err != nil {
+				t.Fatalf("Translate() error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Translate() =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTranslateDistributedWithoutArguments(t *testing.T) {
+	translator, err := New(models.EngineTranslation{Rules: []models.EngineRule{{Rule: models.RuleDistributedCluster, To: "target"}}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if _, err := translator.Translate("CREATE TABLE db.t AS db.u ENGINE = Distributed('source')"); err == nil {
+		t.Errorf("Translate() accepted a Distributed engine without database and table")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		translation models.EngineTranslation
+		wantNil     bool
+		wantErr     string
+	}{
+		{name: "empty", wantNil: true},
+		{name: "preset", translation: models.EngineTranslation{Presets: []string{PresetReplicatedToPlain}}},
+		{name: "macros only", translation: models.EngineTranslation{Macros: map[string]string{"shard": "1"}}},
+		{
+			name:        "unknown preset",
+			translation: models.EngineTranslation{Presets: []string{"replicated-to-cloud"}},
+			wantErr:     `unknown engine preset "replicated-to-cloud", expected "plain-to-replicated", "replicated-to-plain"`,
+		},
+		{
+			name:        "unknown rule",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{{Rule: "rename"}}},
+			wantErr:     `rule 1: unknown rule "rename"`,
+		},
+		{
+			name:        "ZooKeeper path rule without a path",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{{Rule: models.RuleUnreplicate}, {Rule: models.RuleZooKeeperPath}}},
+			wantErr:     "rule 2: zookeeper-path requires zookeeper_path",
+		},
+		{
+			name:        "distributed cluster rule without a target",
+			translation: models.EngineTranslation{Rules: []models.EngineRule{{Rule: models.RuleDistributedCluster, From: "a"}}},
+			wantErr:     "rule 1: distributed-cluster requires to",
+		},
+		{
+			name:        "invalid macro name",
+			translation: models.EngineTranslation{Macros: map[string]string{"{shard}": "1"}},
+			wantErr:     `invalid macro name "{shard}"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			translator, err := New(test.translation)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("New() error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			if (translator == nil) != test.wantNil {
+				t.Errorf("New() = %v, want nil %v", translator, test.wantNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Engine is the engine of a create query: its name and the raw text of its
+// arguments.
+type Engine struct {
+	Name string
+	Args []string
+	// parens tells whether the name is followed by an argument list, which
+	// may be empty.
+	parens bool
+	// start and end delimit the engine name and arguments in the query.
+	start, end int
+}
+
+// String renders the engine as it appears after ENGINE =.
+func (e Engine) String() string {
+	if !e.parens {
+		return e.Name
+	}
+	return e.Name + "(" + strings.Join(e.Args, ", ") + ")"
+}
+
+// Cluster returns the cluster a Distributed engine reads from, or "" for
+// other engines.
+func (e Engine) Cluster() string {
+	if e.Name != "Distributed" || len(e.Args) == 0 {
+		return ""
+	}
+	if cluster, ok := unquote(e.Args[0]); ok {
+		return cluster
+	}
+	return strings.Trim(e.Args[0], "`")
+}
+
+// Parse finds the ENGINE clause of a create query. Only the clause outside
+// any parentheses counts, so column definitions and the SELECT of a view are
+// never mistaken for it.
+func Parse(query string) (Engine, bool) {
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '\'', '"', '`':
+			i = skipQuoted(query, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+		default:
+			if depth != 0 || !keywordAt(query, i, "ENGINE") {
+				continue
+			}
+			j := skipSpaces(query, i+len("ENGINE"))
+			if j >= len(query) || query[j] != '=' {
+				continue
+			}
+			return parseEngine(query, skipSpaces(query, j+1))
+		}
+	}
+	return Engine{}, false
+}
+
+func parseEngine(query string, start int) (Engine, bool) {
+	end := start
+	for end < len(query) && isIdentifier(rune(query[end])) {
+		end++
+	}
+	if end == start {
+		return Engine{}, false
+	}
+	engine := Engine{Name: query[start:end], start: start, end: end}
+	open := skipSpaces(query, end)
+	if open >= len(query) || query[open] != '(' {
+		return engine, true
+	}
+	engine.parens = true
+	depth, argument := 0, open+1
+	for i := open; i < len(query); i++ {
+		switch query[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(query, i)
+		case '(':
+			depth++
+		case ',':
+			if depth == 1 {
+				engine.Args = append(engine.Args, strings.TrimSpace(query[argument:i]))
+				argument = i + 1
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				if last := strings.TrimSpace(query[argument:i]); last != "" || len(engine.Args) > 0 {
+					engine.Args = append(engine.Args, last)
+				}
+				engine.end = i + 1
+				return engine, true
+			}
+		}
+	}
+	return Engine{}, false
+}
+
+// skipQuoted returns the index of the quote closing the one at i, honouring
+// backslash escapes.
+func skipQuoted(query string, i int) int {
+	quote := query[i]
+	for i++; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return len(query)
+}
+
+func skipSpaces(query string, i int) int {
+	for i < len(query) && unicode.IsSpace(rune(query[i])) {
+		i++
+	}
+	return i
+}
+
+// keywordAt tells whether keyword starts at i as a whole word, in any case.
+func keywordAt(query string, i int, keyword string) bool {
+	if i+len(keyword) > len(query) || !strings.EqualFold(query[i:i+len(keyword)], keyword) {
+		return false
+	}
+	if i > 0 && isIdentifier(rune(query[i-1])) {
+		return false
+	}
+	return i+len(keyword) == len(query) || !isIdentifier(rune(query[i+len(keyword)]))
+}
+
+func isIdentifier(c rune) bool {
+	return c == '_' || c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c))
+}
+
+// quote renders value as a ClickHouse string literal.
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// unquote returns the value of a ClickHouse string literal, and false when
+// literal is not one.
+func unquote(literal string) (string, bool) {
+	if len(literal) < 2 || literal[0] != '\'' || literal[len(literal)-1] != '\'' {
+		return "", false
+	}
+	var b strings.Builder
+	body := literal[1 : len(literal)-1]
+	for i := 0; i < len(body); i++ {
+		if body[i] == '\\' && i+1 < len(body) {
+			i++
+		}
+		b.WriteByte(body[i])
+	}
+	return b.String(), true
+}
@@ -0,0 +1,98 @@
+// Package filter selects tables by name with include and exclude patterns.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RegexPrefix marks a pattern as a regular expression. Other patterns are
+// globs in the syntax of path.Match, such as "events_*" or "tmp_?".
+const RegexPrefix = "re:"
+
+type pattern struct {
+	source string
+	regex  *regexp.Regexp
+}
+
+func (p pattern) match(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	matched, _ := path.Match(p.source, name)
+	return matched
+}
+
+// Filter decides which tables take part in a run. A table is selected when it
+// matches an include pattern, or when there are none, and matches no exclude
+// pattern. The zero Filter selects every table.
+type Filter struct {
+	include []pattern
+	exclude []pattern
+}
+
+// New compiles the include and exclude patterns. Regular expressions must
+// match the whole table name.
+func New(include []string, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+	return f, nil
+}
+
+func compile(sources []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(sources))
+	for _, source := range sources {
+		p := pattern{source: source}
+		if expression, ok := strings.CutPrefix(source, RegexPrefix); ok {
+			regex, err := regexp.Compile("^(?:" + expression + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", expression, err)
+			}
+			p.regex = regex
+		} else if _, err := path.Match(source, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", source, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// Match reports whether the table named name is selected.
+func (f *Filter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include) > 0 && !matchAny(f.include, name) {
+		return false
+	}
+	return !matchAny(f.exclude, name)
+}
+
+// Apply splits tables into the selected and the excluded ones, keeping their order.
+func (f *Filter) Apply(tables []string) (selected []string, excluded []string) {
+	for _, table := range tables {
+		if f.Match(table) {
+			selected = append(selected, table)
+		} else {
+			excluded = append(excluded, table)
+		}
+	}
+	return selected, excluded
+}
+
+func matchAny(patterns []pattern, name string) bool {
+	for _, p := range patterns {
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
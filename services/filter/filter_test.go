@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterApply(t *testing.T) {
+	tables := []string{"events", "events_2024", "events_tmp", "users", "tmp_users", "orders"}
+	tests := []struct {
+		name         string
+		include      []string
+		exclude      []string
+		wantSelected []string
+		wantExcluded []string
+	}{
+		{
+			name:         "no patterns",
+			wantSelected: tables,
+		},
+		{
+			name:         "glob include",
+			include:      []string{"events*"},
+			wantSelected: []string{"events", "events_2024", "events_tmp"},
+			wantExcluded: []string{"users", "tmp_users", "orders"},
+		},
+		{
+			name:         "glob matches the whole name",
+			include:      []string{"users"},
+			wantSelected: []string{"users"},
+			wantExcluded: []string{"events", "events_2024", "events_tmp", "tmp_users", "orders"},
+		},
+		{
+			name:         "glob single character",
+			include:      []string{"?sers"},
+			wantSelected: []string{"users"},
+			wantExcluded: []string{"events", "events_2024", "events_tmp", "tmp_users", "orders"},
+		},
+		{
+			name:         "regex include",
+			include:      []string{"re:events_[0-9]+"},
+			wantSelected: []string{"events_2024"},
+			wantExcluded: []string{"events", "events_tmp", "users", "tmp_users", "orders"},
+		},
+		{
+			name:         "regex is anchored",
+			include:      []string{"re:user"},
+			wantExcluded: tables,
+		},
+		{
+			name:         "regex alternation is anchored as a whole",
+			include:      []string{"re:users|orders"},
+			wantSelected: []string{"users", "orders"},
+			wantExcluded: []string{"events", "events_2024", "events_tmp", "tmp_users"},
+		},
+		{
+			name:         "several includes",
+			include:      []string{"users", "re:orders?"},
+			wantSelected: []string{"users", "orders"},
+			wantExcluded: []string{"events", "events_2024", "events_tmp", "tmp_users"},
+		},
+		{
+			name:         "exclude only",
+			exclude:      []string{"*tmp*"},
+			wantSelected: []string{"events", "events_2024", "users", "orders"},
+			wantExcluded: []string{"events_tmp", "tmp_users"},
+		},
+		{
+			name:         "exclude wins over include",
+			include:      []string{"events*"},
+			exclude:      []string{"re:.*_tmp"},
+			wantSelected: []string{"events", "events_2024"},
+			wantExcluded: []string{"events_tmp", "users", "tmp_users", "orders"},
+		},
+		{
+			name:         "exclude wins over an identical include",
+			include:      []string{"users"},
+			exclude:      []string{"users"},
+			wantExcluded: tables,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := New(test.include, test.exclude)
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			selected, excluded := f.Apply(tables)
+			if !reflect.DeepEqual(selected, test.wantSelected) {
+				t.Errorf("selected = %q, want %q", selected, test.wantSelected)
+			}
+			if !reflect.DeepEqual(excluded, test.wantExcluded) {
+				t.Errorf("excluded = %q, want %q", excluded, test.wantExcluded)
+			}
+		})
+	}
+}
+
+func TestNewInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+	}{
+		{name: "invalid include glob", include: []string{"events["}},
+		{name: "invalid include regex", include: []string{"re:events("}},
+		{name: "invalid exclude glob", exclude: []string{"[a-"}},
+		{name: "invalid exclude regex", exclude: []string{"re:*"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := New(test.include, test.exclude); err == nil {
+				t.Errorf("New(%q, %q) succeeded, want an error", test.include, test.exclude)
+			}
+		})
+	}
+}
+
+func TestNilFilterMatchesEverything(t *testing.T) {
+	var f *Filter
+	if !f.Match("events") {
+		t.Errorf("nil Filter did not match")
+	}
+}
@@ -1,27 +1,35 @@
 package generator
 
 import (
+	"context"
 	"os"
 	"os/exec"
+	"strings"
 
+	"github.com/prasannakumar414/click-replicator/datasources/clickhouse"
 	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/secret"
 	"go.uber.org/zap"
 )
 
 type Generator struct {
 	logger       *zap.Logger
 	sourceConfig models.ClickHouseConfig
+	stagingDir   string
 }
 
-func NewGenerator(logger *zap.Logger, config models.ClickHouseConfig) *Generator {
+// NewGenerator returns a Generator that writes its JSONEachRow files to
+// stagingDir, or to the working directory when stagingDir is empty.
+func NewGenerator(logger *zap.Logger, config models.ClickHouseConfig, stagingDir string) *Generator {
 	return &Generator{
 		logger:       logger,
 		sourceConfig: config,
+		stagingDir:   stagingDir,
 	}
 }
 
-func (f *Generator) GenerateFileFromJSON(rows []string, fileName string) error {
-	finalData := ""
+// GenerateFileFromJSON appends rows to fileName, one per line.
+func (f *Generator) GenerateFileFromJSON(ctx context.Context, rows []string, fileName string) error {
 	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		f.logger.Error("error when opening file", zap.Error(err))
@@ -29,21 +37,50 @@ func (f *Generator) GenerateFileFromJSON(rows []string, fileName string) error {
 	}
 	defer file.Close()
 	for _, data := range rows {
-		finalData = data + "\n" // Ensure each entry is on a new line
-	}
-	// Process the data as needed, e.g., write to a file or return it.
-	_, err = file.WriteString(finalData)
-	if err != nil {
-		f.logger.Error("error when writing to file", zap.Error(err))
-		return err
+		if _, err := file.WriteString(data + "\n"); err != nil {
+			f.logger.Error("error when writing to file", zap.Error(err))
+			return err
+		}
 	}
 	return nil
 }
 
-func (f *Generator) GenerateJSONlFromTable(tableName string) (string, error) {
-	cmd := exec.Command("clickhouse-client","--host", f.sourceConfig.Host,"--query", "SELECT * FROM "+f.sourceConfig.Database+"."+tableName+" FORMAT JSONEachRow")
-	fileName := tableName + "_final.jsonl"
-	file, err := os.Create(fileName)
+func (f *Generator) GenerateJSONlFromTable(ctx context.Context, tableName string) (string, error) {
+	return f.GenerateJSONlFromTableWhere(ctx, tableName, "")
+}
+
+// GenerateJSONlFromTableWhere exports the rows matching condition, a WHERE
+// clause or an empty string for the whole table.
+func (f *Generator) GenerateJSONlFromTableWhere(ctx context.Context, tableName string, condition string) (string, error) {
+	return f.GenerateJSONlFromColumnsWhere(ctx, tableName, nil, condition)
+}
+
+// GenerateJSONlFromColumnsWhere exports columns of the rows matching
+// condition, or every column when columns is empty. The destination fills
+// the fields missing from the file with their defaults.
+func (f *Generator) GenerateJSONlFromColumnsWhere(ctx context.Context, tableName string, columns []string, condition string) (string, error) {
+	selected := "*"
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = "`" + strings.ReplaceAll(column, "`", "\\`") + "`"
+		}
+		selected = strings.Join(quoted, ", ")
+	}
+	query := "SELECT " + selected + " FROM " + f.sourceConfig.Database + "." + tableName + " " + condition + " FORMAT JSONEachRow"
+	args, err := clickhouse.ClientArgs(f.sourceConfig)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "clickhouse-client", append(args, "--query", query)...)
+	cmd.Env = secret.ClientEnv(f.sourceConfig)
+	// Every run gets its own file, so tables of the same name in different
+	// databases do not overwrite each other's rows.
+	stagingDir := f.stagingDir
+	if stagingDir == "" {
+		stagingDir = "."
+	}
+	file, err := os.CreateTemp(stagingDir, f.sourceConfig.Database+"."+tableName+"_*.jsonl")
 	if err != nil {
 		return "",err
 	}
@@ -53,8 +90,9 @@ func (f *Generator) GenerateJSONlFromTable(tableName string) (string, error) {
 
 	err = cmd.Run()
 	if err != nil {
+		os.Remove(file.Name())
 		return "",err
 	}
 
-	return fileName, nil
+	return file.Name(), nil
 }
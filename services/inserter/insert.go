@@ -1,12 +1,15 @@
 package inserter
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 
+	"github.com/prasannakumar414/click-replicator/datasources/clickhouse"
 	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/secret"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
 )
@@ -22,48 +25,103 @@ func NewInserter(config models.ClickHouseConfig) *Inserter {
 }
 
 func (submitter *Inserter) InsertToClickhouse(ctx context.Context, logger *zap.Logger, table string, ingestionFilePath string, format string) error {
-	commandTemplate := `
-#!/bin/bash
-set -euf -o pipefail
-cat %s | clickhouse-client --host=%s \
-				  --input_format_skip_unknown_fields=1 \
-				  --database=%s \
-				  --http_send_timeout=3600 \
-				  --receive_timeout=30000 \
-				  --tcp_keep_alive_timeout=2000 \
-				  --http_receive_timeout=600 \
-				  --max_insert_block_size=80000 \
-				  --min_compress_block_size=262144 \
-				  --max_memory_usage=55000000000 \
-				  --query="INSERT INTO %s Format %s" \
-				  --stacktrace
-`
-	submitCommand := fmt.Sprintf(commandTemplate, ingestionFilePath, submitter.clickhouseConfig.Host, submitter.clickhouseConfig.Database, table, format)
-	logger.Info("Executing command", zap.String("command", submitCommand))
-	cmd := exec.CommandContext(ctx, "bash", "-c", submitCommand)
+	_, err := submitter.InsertToClickhouseWithCount(ctx, logger, table, ingestionFilePath, format)
+	return err
+}
+
+// InsertToClickhouseWithCount is InsertToClickhouse returning the number of
+// rows in the ingestion file, one per line. The file is deleted afterwards,
+// also when the insert fails.
+func (submitter *Inserter) InsertToClickhouseWithCount(ctx context.Context, logger *zap.Logger, table string, ingestionFilePath string, format string) (rows uint64, err error) {
+	defer func() {
+		if err != nil {
+			os.Remove(ingestionFilePath)
+		}
+	}()
+	rows, err = countLines(ingestionFilePath)
+	if err != nil {
+		return 0, err
+	}
+	args, err := insertArgs(submitter.clickhouseConfig, table, format)
+	if err != nil {
+		return 0, err
+	}
+	input, err := os.Open(ingestionFilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer input.Close()
+	logger.Debug("Executing command", zap.Strings("args", args))
+	cmd := exec.CommandContext(ctx, "clickhouse-client", args...)
+	cmd.Stdin = input
+	cmd.Env = secret.ClientEnv(submitter.clickhouseConfig)
 	stdout := &strings.Builder{}
 	stderr := &strings.Builder{}
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	if err := cmd.Run(); err != nil {
-		return &SubmissionError{
-			Stdout:   stdout.String(),
-			Stderr:   stderr.String(),
+		redactor := secret.NewRedactor(submitter.clickhouseConfig.Password)
+		return 0, &SubmissionError{
+			Stdout:   redactor.String(stdout.String()),
+			Stderr:   redactor.String(stderr.String()),
 			URI:      ingestionFilePath,
 			ExitCode: cmd.ProcessState.ExitCode(),
 		}
 
 	}
-	err := os.Remove(ingestionFilePath)
+	input.Close()
+	err = os.Remove(ingestionFilePath)
 	if err != nil {
 		logger.Error("Error deleting file:", zap.Error(err))
-		return err
+		return rows, err
 	}
 
 	logger.Info("File deleted successfully")
-	return nil
+	return rows, nil
+}
+
+// insertArgs returns the clickhouse-client arguments inserting the rows read
+// from stdin into table of the configured database.
+func insertArgs(config models.ClickHouseConfig, table string, format string) ([]string, error) {
+	args, err := clickhouse.ClientArgs(config)
+	if err != nil {
+		return nil, err
+	}
+	return append(args,
+		"--database", config.Database,
+		"--input_format_skip_unknown_fields=1",
+		"--http_send_timeout=3600",
+		"--receive_timeout=30000",
+		"--tcp_keep_alive_timeout=2000",
+		"--http_receive_timeout=600",
+		"--max_insert_block_size=80000",
+		"--min_compress_block_size=262144",
+		"--max_memory_usage=55000000000",
+		"--query", fmt.Sprintf("INSERT INTO %s FORMAT %s", table, format),
+		"--stacktrace",
+	), nil
+}
+
+func countLines(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var lines uint64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			lines++
+		}
+	}
+	return lines, scanner.Err()
 }
 
+// SubmissionError reports a failed clickhouse-client run. The inserter masks
+// its password in Stdout and Stderr.
 type SubmissionError struct {
 	Stdout   string
 	Stderr   string
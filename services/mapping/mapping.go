@@ -0,0 +1,93 @@
+// Package mapping resolves the destination name of replicated tables.
+package mapping
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+// TablePlaceholder is replaced by the table name in a template.
+const TablePlaceholder = "{table}"
+
+type rewrite struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// Mapper applies a models.TableMapping. The zero Mapper, like a nil one,
+// keeps every name unchanged.
+type Mapper struct {
+	tables   map[string]string
+	rewrites []rewrite
+	template string
+}
+
+// New compiles mapping.
+func New(mapping models.TableMapping) (*Mapper, error) {
+	m := &Mapper{tables: mapping.Tables, template: mapping.Template}
+	if m.template != "" && !strings.Contains(m.template, TablePlaceholder) {
+		return nil, fmt.Errorf("template %q does not contain %s", m.template, TablePlaceholder)
+	}
+	for source, destination := range mapping.Tables {
+		if source == "" || destination == "" {
+			return nil, fmt.Errorf("table mapping %q -> %q has an empty name", source, destination)
+		}
+	}
+	for _, r := range mapping.Rewrites {
+		pattern, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite pattern %q: %w", r.Pattern, err)
+		}
+		m.rewrites = append(m.rewrites, rewrite{pattern: pattern, replacement: r.Replacement})
+	}
+	return m, nil
+}
+
+// Table returns the destination name of the source table named table.
+func (m *Mapper) Table(table string) string {
+	if m == nil {
+		return table
+	}
+	if destination, ok := m.tables[table]; ok {
+		return destination
+	}
+	name := table
+	for _, r := range m.rewrites {
+		if r.pattern.MatchString(name) {
+			name = r.pattern.ReplaceAllString(name, r.replacement)
+			break
+		}
+	}
+	if m.template != "" {
+		name = strings.ReplaceAll(m.template, TablePlaceholder, name)
+	}
+	return name
+}
+
+// Check returns an error when two of tables map to the same destination name
+// or a table maps to an empty name.
+func (m *Mapper) Check(tables []string) error {
+	sources := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		destination := m.Table(table)
+		if destination == "" {
+			return fmt.Errorf("table %s maps to an empty name", table)
+		}
+		sources[destination] = append(sources[destination], table)
+	}
+	var conflicts []string
+	for destination, names := range sources {
+		if len(names) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%s <- %s", destination, strings.Join(names, ", ")))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("several tables map to the same destination: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
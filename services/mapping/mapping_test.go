@@ -0,0 +1,162 @@
+package mapping
+
+import (
+	"testing"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+func TestMapperTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping models.TableMapping
+		table   string
+		want    string
+	}{
+		{
+			name:  "no mapping",
+			table: "events",
+			want:  "events",
+		},
+		{
+			name:    "pair",
+			mapping: models.TableMapping{Tables: map[string]string{"events": "events_v2"}},
+			table:   "events",
+			want:    "events_v2",
+		},
+		{
+			name:    "table without pair",
+			mapping: models.TableMapping{Tables: map[string]string{"events": "events_v2"}},
+			table:   "users",
+			want:    "users",
+		},
+		{
+			name:    "rewrite",
+			mapping: models.TableMapping{Rewrites: []models.Rewrite{{Pattern: "tmp_(.*)", Replacement: "$1"}}},
+			table:   "tmp_users",
+			want:    "users",
+		},
+		{
+			name:    "rewrite with named group",
+			mapping: models.TableMapping{Rewrites: []models.Rewrite{{Pattern: "(?P<name>.*)_old", Replacement: "${name}_archive"}}},
+			table:   "users_old",
+			want:    "users_archive",
+		},
+		{
+			name:    "rewrite matches the whole name",
+			mapping: models.TableMapping{Rewrites: []models.Rewrite{{Pattern: "tmp", Replacement: "scratch"}}},
+			table:   "tmp_users",
+			want:    "tmp_users",
+		},
+		{
+			name: "first matching rewrite wins",
+			mapping: models.TableMapping{Rewrites: []models.Rewrite{
+				{Pattern: "events_(.*)", Replacement: "e_$1"},
+				{Pattern: "(.*)_2024", Replacement: "$1"},
+			}},
+			table: "events_2024",
+			want:  "e_2024",
+		},
+		{
+			name:    "template",
+			mapping: models.TableMapping{Template: "stg_{table}"},
+			table:   "users",
+			want:    "stg_users",
+		},
+		{
+			name: "template applies to the rewritten name",
+			mapping: models.TableMapping{
+				Rewrites: []models.Rewrite{{Pattern: "tmp_(.*)", Replacement: "$1"}},
+				Template: "{table}_v2",
+			},
+			table: "tmp_users",
+			want:  "users_v2",
+		},
+		{
+			name: "pair wins over rewrites and template",
+			mapping: models.TableMapping{
+				Tables:   map[string]string{"tmp_users": "people"},
+				Rewrites: []models.Rewrite{{Pattern: "tmp_(.*)", Replacement: "$1"}},
+				Template: "stg_{table}",
+			},
+			table: "tmp_users",
+			want:  "people",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := New(test.mapping)
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			if got := m.Table(test.table); got != test.want {
+				t.Errorf("Table(%q) = %q, want %q", test.table, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewInvalidMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping models.TableMapping
+	}{
+		{name: "template without placeholder", mapping: models.TableMapping{Template: "stg_table"}},
+		{name: "empty destination", mapping: models.TableMapping{Tables: map[string]string{"events": ""}}},
+		{name: "invalid rewrite pattern", mapping: models.TableMapping{Rewrites: []models.Rewrite{{Pattern: "tmp_(", Replacement: "$1"}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := New(test.mapping); err == nil {
+				t.Errorf("New(%+v) succeeded, want an error", test.mapping)
+			}
+		})
+	}
+}
+
+func TestMapperCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping models.TableMapping
+		tables  []string
+		wantErr bool
+	}{
+		{
+			name:   "distinct names",
+			tables: []string{"events", "users"},
+		},
+		{
+			name:    "pair collides with another table",
+			mapping: models.TableMapping{Tables: map[string]string{"events": "users"}},
+			tables:  []string{"events", "users"},
+			wantErr: true,
+		},
+		{
+			name:    "rewrite collides",
+			mapping: models.TableMapping{Rewrites: []models.Rewrite{{Pattern: "tmp_(.*)", Replacement: "$1"}}},
+			tables:  []string{"tmp_users", "users"},
+			wantErr: true,
+		},
+		{
+			name:    "rewrite to an empty name",
+			mapping: models.TableMapping{Rewrites: []models.Rewrite{{Pattern: "tmp_.*", Replacement: ""}}},
+			tables:  []string{"tmp_users"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := New(test.mapping)
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			err = m.Check(test.tables)
+			if test.wantErr && err == nil {
+				t.Errorf("Check(%q) succeeded, want an error", test.tables)
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("Check(%q) error: %v", test.tables, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// CheckpointStore records which chunks of the tables in progress have
+// completed so an interrupted run can resume where it stopped.
+type CheckpointStore interface {
+	Status(ctx context.Context, table string, chunk string) (models.CheckpointStatus, error)
+	SetStatus(ctx context.Context, table string, chunk string, status models.CheckpointStatus) error
+	ResetTable(ctx context.Context, table string) error
+	Reset(ctx context.Context) error
+}
+
+func (n *Replicator) checkpointStatus(ctx context.Context, table string, chunk string) (models.CheckpointStatus, error) {
+	if n.checkpoints == nil {
+		return models.CheckpointNone, nil
+	}
+	status, err := n.checkpoints.Status(ctx, tableKey(n.destination.Database(), n.target(table)), chunk)
+	if err != nil {
+		return models.CheckpointNone, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	return status, nil
+}
+
+// resetCheckpoints discards the checkpoints of a table that has been
+// replicated, so the next run checks it against the source again.
+func (n *Replicator) resetCheckpoints(ctx context.Context, table string) error {
+	if n.checkpoints == nil {
+		return nil
+	}
+	if err := n.checkpoints.ResetTable(ctx, tableKey(n.destination.Database(), n.target(table))); err != nil {
+		return fmt.Errorf("clearing checkpoints: %w", err)
+	}
+	return nil
+}
+
+func (n *Replicator) setCheckpoint(ctx context.Context, table string, chunk string, status models.CheckpointStatus) error {
+	if n.checkpoints == nil {
+		return nil
+	}
+	if err := n.checkpoints.SetStatus(ctx, tableKey(n.destination.Database(), n.target(table)), chunk, status); err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+// copyChunk runs one unit of work under a checkpoint. Finished chunks are
+// skipped; a chunk left started by an interrupted run has its partial rows
+// removed by discard before it is copied again.
+//
+// No chunk is started once ctx is cancelled, but a chunk that has started
+// runs to completion on a context without the cancellation, so a stopped run
+// never leaves half a chunk on the destination.
+func (n *Replicator) copyChunk(ctx context.Context, table string, chunk string, discard func(ctx context.Context) error, copy func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	status, err := n.checkpointStatus(ctx, table, chunk)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case models.CheckpointDone:
+		n.logger.Info("Skipping chunk completed by a previous run", zap.String("table", table), zap.String("chunk", chunk))
+		return nil
+	case models.CheckpointStarted:
+		n.logger.Info("Discarding partial chunk from an interrupted run", zap.String("table", table), zap.String("chunk", chunk))
+		if err := discard(ctx); err != nil {
+			return fmt.Errorf("discarding partial chunk %s: %w", chunk, err)
+		}
+	}
+	if err := n.setCheckpoint(ctx, table, chunk, models.CheckpointStarted); err != nil {
+		return err
+	}
+	if err := copy(ctx); err != nil {
+		return err
+	}
+	return n.setCheckpoint(ctx, table, chunk, models.CheckpointDone)
+}
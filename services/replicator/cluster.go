@@ -0,0 +1,76 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// checkCluster makes sure the destination defines the cluster its DDL runs
+// on, so a misspelt name fails before anything is created.
+func (n *Replicator) checkCluster(ctx context.Context) error {
+	cluster := n.destination.Cluster()
+	if cluster == "" {
+		return nil
+	}
+	clusters, err := n.destination.GetClusters(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching destination clusters: %w", err)
+	}
+	if !slices.Contains(clusters, cluster) {
+		return fmt.Errorf("destination does not define cluster %s, it knows %s", cluster, strings.Join(clusters, ", "))
+	}
+	return nil
+}
+
+// wrapperName returns the name of the Distributed table over destinationTable.
+func (n *Replicator) wrapperName(destinationTable string) string {
+	return destinationTable + n.distributed
+}
+
+// isWrapper tells whether a destination table is the Distributed table over
+// one of targets.
+func (n *Replicator) isWrapper(table string, targets map[string]bool) bool {
+	if n.distributed == "" || n.destination.Cluster() == "" {
+		return false
+	}
+	base, ok := strings.CutSuffix(table, n.distributed)
+	return ok && targets[base]
+}
+
+// planWrapper adds the creation of the Distributed table over a table to its
+// plan, when wrappers are asked for and it does not exist yet.
+func (n *Replicator) planWrapper(ctx context.Context, plan *tablePlan) error {
+	if n.distributed == "" || plan.Kind != models.KindTable || plan.Engine == distributedEngine || plan.Action == models.ActionUnsupported {
+		return nil
+	}
+	query := n.destination.DistributedTableQuery(plan.Destination, n.wrapperName(plan.Destination))
+	if query == "" {
+		return nil
+	}
+	exists, err := n.destination.IsTableExists(ctx, n.wrapperName(plan.Destination))
+	if err != nil {
+		return fmt.Errorf("checking if distributed table exists: %w", err)
+	}
+	if !exists {
+		plan.wrapper = query
+		plan.DDL = append(plan.DDL, query)
+	}
+	return nil
+}
+
+// createWrapper creates the Distributed table planned by planWrapper.
+func (n *Replicator) createWrapper(ctx context.Context, plan tablePlan) error {
+	if plan.wrapper == "" {
+		return nil
+	}
+	n.logger.Info("Creating distributed table", zap.String("table", n.wrapperName(plan.Destination)), zap.String("query", plan.wrapper))
+	if err := n.destination.ExecuteDDL(ctx, plan.wrapper); err != nil {
+		return fmt.Errorf("creating distributed table: %w", err)
+	}
+	return nil
+}
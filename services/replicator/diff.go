@@ -0,0 +1,303 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/engine"
+)
+
+// Diff compares the tables, columns, engines, keys and TTLs of the source
+// with the destination and works out the statements that would bring the
+// destination in line. Destination tables that no source table maps to are
+// reported as extra.
+func (n *Replicator) Diff(ctx context.Context) (*models.SchemaDiff, error) {
+	allTables, err := n.source.GetAllTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching source tables: %w", err)
+	}
+	selected, _, err := n.listTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching source tables: %w", err)
+	}
+	tables, objects, err := n.splitObjects(ctx, selected)
+	if err != nil {
+		return nil, err
+	}
+	tables = append(tables, objects...)
+	destinationTables, err := n.destination.GetAllTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching destination tables: %w", err)
+	}
+
+	diff := &models.SchemaDiff{
+		SourceDatabase:      n.source.Database(),
+		DestinationDatabase: n.destination.Database(),
+		Match:               true,
+	}
+	targets := make(map[string]bool, len(allTables))
+	for _, table := range allTables {
+		targets[n.target(table)] = true
+	}
+	for _, table := range tables {
+		tableDiff := n.diffTable(ctx, table)
+		if tableDiff.Status != models.DiffSame {
+			diff.Match = false
+		}
+		diff.Tables = append(diff.Tables, tableDiff)
+	}
+	for _, table := range destinationTables {
+		if targets[table] || isInnerTable(table) || n.isWrapper(table, targets) || !n.filter.Match(table) {
+			continue
+		}
+		diff.Match = false
+		diff.Tables = append(diff.Tables, models.TableDiff{
+			Destination: table,
+			Status:      models.DiffExtra,
+			Migration:   []string{commentOut("not on the source, drop it if it is no longer needed", n.destination.DropTableQuery(table))},
+		})
+	}
+	return diff, nil
+}
+
+func (n *Replicator) diffTable(ctx context.Context, table string) models.TableDiff {
+	tableDiff := models.TableDiff{Table: table, Destination: n.target(table), Status: models.DiffSame}
+	fail := func(err error) models.TableDiff {
+		tableDiff.Status, tableDiff.Error = models.DiffChanged, n.redactor.String(err.Error())
+		return tableDiff
+	}
+	exists, err := n.destination.IsTableExists(ctx, tableDiff.Destination)
+	if err != nil {
+		return fail(err)
+	}
+	if !exists {
+		tableDiff.Status = models.DiffMissing
+		engine, err := n.source.GetTableEngine(ctx, table)
+		if err != nil {
+			return fail(fmt.Errorf("fetching source engine: %w", err))
+		}
+		ddl, err := n.cloner.CreateTableQuery(ctx, table, tableDiff.Destination)
+		if objectKind(engine) != models.KindTable {
+			ddl, err = n.cloner.CreateObjectQuery(ctx, table, tableDiff.Destination, n.target)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("building create query: %w", err))
+		}
+		tableDiff.Migration = []string{n.destination.ClusterQuery(ddl)}
+		return tableDiff
+	}
+	sourceColumns, err := n.source.GetColumns(ctx, table)
+	if err != nil {
+		return fail(fmt.Errorf("source columns: %w", err))
+	}
+	destinationColumns, err := n.destination.GetColumns(ctx, tableDiff.Destination)
+	if err != nil {
+		return fail(fmt.Errorf("destination columns: %w", err))
+	}
+	sourceSchema, err := n.source.GetTableSchema(ctx, table)
+	if err != nil {
+		return fail(fmt.Errorf("source schema: %w", err))
+	}
+	destinationSchema, err := n.destination.GetTableSchema(ctx, tableDiff.Destination)
+	if err != nil {
+		return fail(fmt.Errorf("destination schema: %w", err))
+	}
+	if objectKind(sourceSchema.Engine) == models.KindTable {
+		// Compare with the engine the table would be created with, after
+		// the engine translation.
+		ddl, err := n.cloner.CreateTableQuery(ctx, table, tableDiff.Destination)
+		if err != nil {
+			return fail(fmt.Errorf("building create query: %w", err))
+		}
+		if created, ok := engine.Parse(ddl); ok {
+			sourceSchema.Engine = created.Name
+		}
+	}
+	tableDiff.Columns = diffColumns(sourceColumns, destinationColumns)
+	tableDiff.Properties = diffProperties(sourceSchema, destinationSchema)
+	if len(tableDiff.Properties) > 0 {
+		tableDiff.Status = models.DiffChanged
+	}
+	for _, column := range tableDiff.Columns {
+		if column.Status != models.DiffSame {
+			tableDiff.Status = models.DiffChanged
+		}
+	}
+	if tableDiff.Status == models.DiffChanged {
+		tableDiff.Migration, err = n.migration(ctx, table, tableDiff, sourceColumns, sourceSchema)
+		if err != nil {
+			return fail(err)
+		}
+	}
+	return tableDiff
+}
+
+// diffColumns pairs columns by name, in source order followed by the columns
+// found on the destination only.
+func diffColumns(source []models.Column, destination []models.Column) []models.ColumnDiff {
+	destinationColumns := make(map[string]models.Column, len(destination))
+	for _, column := range destination {
+		destinationColumns[column.Name] = column
+	}
+	sourceNames := make(map[string]bool, len(source))
+	var diffs []models.ColumnDiff
+	for _, column := range source {
+		sourceNames[column.Name] = true
+		columnDiff := models.ColumnDiff{Column: column.Name, SourceType: column.Type, SourceDefault: columnDefault(column), Status: models.DiffSame}
+		destinationColumn, ok := destinationColumns[column.Name]
+		if !ok {
+			columnDiff.Status = models.DiffMissing
+			diffs = append(diffs, columnDiff)
+			continue
+		}
+		columnDiff.DestinationType = destinationColumn.Type
+		columnDiff.DestinationDefault = columnDefault(destinationColumn)
+		if isNullable(column.Type) != isNullable(destinationColumn.Type) {
+			columnDiff.Changes = append(columnDiff.Changes, models.ColumnNullable)
+		}
+		if baseType(column.Type) != baseType(destinationColumn.Type) {
+			columnDiff.Changes = append(columnDiff.Changes, models.ColumnType)
+		}
+		if columnDiff.SourceDefault != columnDiff.DestinationDefault {
+			columnDiff.Changes = append(columnDiff.Changes, models.ColumnDefault)
+		}
+		if len(columnDiff.Changes) > 0 {
+			columnDiff.Status = models.DiffChanged
+		}
+		diffs = append(diffs, columnDiff)
+	}
+	for _, column := range destination {
+		if !sourceNames[column.Name] {
+			diffs = append(diffs, models.ColumnDiff{Column: column.Name, Status: models.DiffExtra, DestinationType: column.Type, DestinationDefault: columnDefault(column)})
+		}
+	}
+	return diffs
+}
+
+// diffProperties lists the table level properties that differ.
+func diffProperties(source models.TableSchema, destination models.TableSchema) []models.PropertyDiff {
+	properties := []models.PropertyDiff{
+		{Property: "engine", Source: source.Engine, Destination: destination.Engine},
+		{Property: "partition key", Source: source.PartitionKey, Destination: destination.PartitionKey},
+		{Property: "sorting key", Source: source.SortingKey, Destination: destination.SortingKey},
+		{Property: "primary key", Source: source.PrimaryKey, Destination: destination.PrimaryKey},
+		{Property: "ttl", Source: source.TTL, Destination: destination.TTL},
+	}
+	var diffs []models.PropertyDiff
+	for _, property := range properties {
+		if property.Source != property.Destination {
+			diffs = append(diffs, property)
+		}
+	}
+	return diffs
+}
+
+// migration returns the statements that bring the destination table of a
+// changed table in line with the source. Columns are added, modified and
+// their defaults removed in place; dropping columns loses data and a
+// different engine or key needs the table recreated, so those statements are
+// commented out for review.
+func (n *Replicator) migration(ctx context.Context, table string, tableDiff models.TableDiff, sourceColumns []models.Column, sourceSchema models.TableSchema) ([]string, error) {
+	name := n.destination.Database() + "." + tableDiff.Destination
+	sourceByName := make(map[string]models.Column, len(sourceColumns))
+	previous := make(map[string]string, len(sourceColumns))
+	for i, column := range sourceColumns {
+		sourceByName[column.Name] = column
+		if i > 0 {
+			previous[column.Name] = sourceColumns[i-1].Name
+		}
+	}
+
+	var statements []string
+	recreate := false
+	for _, property := range tableDiff.Properties {
+		switch property.Property {
+		case "ttl":
+			if sourceSchema.TTL == "" {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s REMOVE TTL", name))
+			} else {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s MODIFY TTL %s", name, sourceSchema.TTL))
+			}
+		default:
+			recreate = true
+		}
+	}
+	for _, column := range tableDiff.Columns {
+		switch column.Status {
+		case models.DiffMissing:
+			position := "FIRST"
+			if after, ok := previous[column.Column]; ok {
+				position = "AFTER " + quoteIdentifier(after)
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", name, columnDefinition(sourceByName[column.Column]), position))
+		case models.DiffChanged:
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", name, columnDefinition(sourceByName[column.Column])))
+			if column.SourceDefault == "" && column.DestinationDefault != "" {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s REMOVE DEFAULT", name, quoteIdentifier(column.Column)))
+			}
+		case models.DiffExtra:
+			statements = append(statements, commentOut("not on the source, loses its data", fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", name, quoteIdentifier(column.Column))))
+		}
+	}
+	if recreate {
+		// The recreated table has the source columns, so no column statement applies.
+		ddl, err := n.cloner.CreateTableQuery(ctx, table, tableDiff.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("building create query: %w", err)
+		}
+		statements = []string{commentOut("engine or keys differ and cannot be altered in place; recreate the table and copy its rows again",
+			n.destination.DropTableQuery(tableDiff.Destination), n.destination.ClusterQuery(ddl))}
+	}
+	for i, statement := range statements {
+		statements[i] = n.destination.ClusterQuery(statement)
+	}
+	return statements, nil
+}
+
+// commentOut renders statements as SQL comments preceded by why they are not
+// run as is.
+func commentOut(reason string, statements ...string) string {
+	lines := []string{"-- " + reason + ":"}
+	for _, statement := range statements {
+		for _, line := range strings.Split(statement, "\n") {
+			lines = append(lines, "-- "+line)
+		}
+		lines[len(lines)-1] += ";"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// columnDefinition renders a column as in CREATE TABLE.
+func columnDefinition(column models.Column) string {
+	definition := quoteIdentifier(column.Name) + " " + column.Type
+	if columnDefault := columnDefault(column); columnDefault != "" {
+		definition += " " + columnDefault
+	}
+	if column.CompressionCodec != "" {
+		definition += " " + column.CompressionCodec
+	}
+	return definition
+}
+
+// columnDefault renders the default of a column as kind and expression, or
+// returns an empty string when it has none.
+func columnDefault(column models.Column) string {
+	if column.DefaultKind == "" {
+		return ""
+	}
+	return column.DefaultKind + " " + column.DefaultExpression
+}
+
+func isNullable(columnType string) bool {
+	return strings.HasPrefix(columnType, "Nullable(")
+}
+
+// baseType strips the Nullable wrapper from a column type.
+func baseType(columnType string) string {
+	if isNullable(columnType) {
+		return strings.TrimSuffix(strings.TrimPrefix(columnType, "Nullable("), ")")
+	}
+	return columnType
+}
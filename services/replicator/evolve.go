@@ -0,0 +1,184 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// schemaChanges compares the columns of table with its destination table and
+// returns the changes to carry over. A column missing on the destination and
+// one missing on the source are taken for a rename only when they have the
+// same position, type and default and the same column follows them on both
+// sides. ClickHouse appends added columns, so a column dropped from the end
+// and another one added there are a drop and an add. Changes of a column
+// default alone do not affect inserts and are left to Diff.
+func (n *Replicator) schemaChanges(ctx context.Context, table string, destinationTable string) ([]models.SchemaChange, error) {
+	sourceColumns, err := n.source.GetColumns(ctx, table)
+	if err != nil {
+		return nil, fmt.Errorf("fetching source columns: %w", err)
+	}
+	destinationColumns, err := n.destination.GetColumns(ctx, destinationTable)
+	if err != nil {
+		return nil, fmt.Errorf("fetching destination columns: %w", err)
+	}
+	name := n.destination.Database() + "." + destinationTable
+
+	sourceNames := make(map[string]bool, len(sourceColumns))
+	for _, column := range sourceColumns {
+		sourceNames[column.Name] = true
+	}
+	dropped := make(map[uint64]models.Column)
+	for _, column := range destinationColumns {
+		if !sourceNames[column.Name] {
+			dropped[column.Position] = column
+		}
+	}
+	destinationByName := make(map[string]models.Column, len(destinationColumns))
+	destinationByPosition := make(map[uint64]models.Column, len(destinationColumns))
+	for _, column := range destinationColumns {
+		destinationByName[column.Name] = column
+		destinationByPosition[column.Position] = column
+	}
+	renamed := func(i int, previous models.Column) bool {
+		column := sourceColumns[i]
+		if previous.Type != column.Type || columnDefault(previous) != columnDefault(column) || i+1 == len(sourceColumns) {
+			return false
+		}
+		next, ok := destinationByPosition[previous.Position+1]
+		return ok && next.Name == sourceColumns[i+1].Name
+	}
+
+	var changes []models.SchemaChange
+	for i, column := range sourceColumns {
+		destinationColumn, ok := destinationByName[column.Name]
+		switch {
+		case !ok:
+			if previous, ok := dropped[column.Position]; ok && renamed(i, previous) {
+				delete(dropped, column.Position)
+				changes = append(changes, models.SchemaChange{
+					Kind:         models.ChangeRenamed,
+					Column:       column.Name,
+					PreviousName: previous.Name,
+					Type:         column.Type,
+					Statement:    fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", name, quoteIdentifier(previous.Name), quoteIdentifier(column.Name)),
+				})
+				continue
+			}
+			position := "FIRST"
+			if i > 0 {
+				position = "AFTER " + quoteIdentifier(sourceColumns[i-1].Name)
+			}
+			changes = append(changes, models.SchemaChange{
+				Kind:      models.ChangeAdded,
+				Column:    column.Name,
+				Type:      column.Type,
+				Statement: fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", name, columnDefinition(column), position),
+			})
+		case destinationColumn.Type != column.Type:
+			changes = append(changes, models.SchemaChange{
+				Kind:         models.ChangeRetyped,
+				Column:       column.Name,
+				Type:         column.Type,
+				PreviousType: destinationColumn.Type,
+				Statement:    fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", name, quoteIdentifier(column.Name), column.Type),
+			})
+		}
+	}
+	for _, column := range destinationColumns {
+		if _, ok := dropped[column.Position]; ok && !sourceNames[column.Name] {
+			changes = append(changes, models.SchemaChange{
+				Kind:      models.ChangeDropped,
+				Column:    column.Name,
+				Type:      column.Type,
+				Statement: fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", name, quoteIdentifier(column.Name)),
+			})
+		}
+	}
+	return changes, nil
+}
+
+// evolveSchema acts on the schema changes of a plan according to the schema
+// policy and records them in result. Changes run in source column order, so
+// an added column can follow a column added or renamed just before it. It
+// returns the columns to copy when changes left behind restrict them, or nil
+// to copy every column.
+func (n *Replicator) evolveSchema(ctx context.Context, table string, plan tablePlan, result *models.TableResult) ([]string, error) {
+	if len(plan.SchemaChanges) == 0 {
+		return nil, nil
+	}
+	changes := append([]models.SchemaChange(nil), plan.SchemaChanges...)
+	result.SchemaChanges = changes
+	switch n.schema {
+	case models.SchemaApply:
+		for i := range changes {
+			n.logger.Info("Applying schema change", zap.String("table", table), zap.Stringer("change", changes[i]), zap.String("query", changes[i].Statement))
+			if err := n.destination.ExecuteDDL(ctx, changes[i].Statement); err != nil {
+				return nil, fmt.Errorf("applying schema change (%s): %w", changes[i], err)
+			}
+			changes[i].Applied = true
+		}
+		return nil, nil
+	case models.SchemaFail:
+		descriptions := make([]string, len(changes))
+		for i, change := range changes {
+			descriptions[i] = change.String()
+		}
+		return nil, fmt.Errorf("source schema changed: %s", strings.Join(descriptions, "; "))
+	default:
+		for _, change := range changes {
+			n.logger.Warn("Source schema changed, copying without the change", zap.String("table", table), zap.Stringer("change", change))
+		}
+		return n.commonColumns(ctx, table, changes)
+	}
+}
+
+// commonColumns returns the source columns that exist with the same type on
+// the destination despite changes, or nil when that is every column. Columns
+// dropped from the source only take their default on the destination.
+func (n *Replicator) commonColumns(ctx context.Context, table string, changes []models.SchemaChange) ([]string, error) {
+	left := make(map[string]bool)
+	for _, change := range changes {
+		switch change.Kind {
+		case models.ChangeAdded, models.ChangeRenamed, models.ChangeRetyped:
+			left[change.Column] = true
+		}
+	}
+	if len(left) == 0 {
+		return nil, nil
+	}
+	sourceColumns, err := n.source.GetColumns(ctx, table)
+	if err != nil {
+		return nil, fmt.Errorf("fetching source columns: %w", err)
+	}
+	var columns []string
+	for _, column := range sourceColumns {
+		if !left[column.Name] {
+			columns = append(columns, column.Name)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("source schema changed and no column is left to copy, apply the changes with the apply schema policy")
+	}
+	n.logger.Warn("Copying the columns the source and destination share", zap.String("table", table), zap.Strings("columns", columns))
+	return columns, nil
+}
+
+type columnsKey struct{}
+
+// withColumns returns a context under which copyRows copies only columns,
+// or every column when columns is nil.
+func withColumns(ctx context.Context, columns []string) context.Context {
+	if columns == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, columnsKey{}, columns)
+}
+
+func copyColumns(ctx context.Context) []string {
+	columns, _ := ctx.Value(columnsKey{}).([]string)
+	return columns
+}
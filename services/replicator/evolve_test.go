@@ -0,0 +1,277 @@
+package replicator
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// fakeSource is a DataSource serving the columns, engines and create
+// queries of its tables. Other methods are not implemented and panic.
+type fakeSource struct {
+	DataSource
+	database string
+	columns  map[string][]models.Column
+	engines  map[string]string
+	creates  map[string]string
+	clusters []string
+}
+
+func (f *fakeSource) Database() string {
+	return f.database
+}
+
+func (f *fakeSource) GetColumns(ctx context.Context, tableName string) ([]models.Column, error) {
+	return f.columns[tableName], nil
+}
+
+func (f *fakeSource) GetTableEngine(ctx context.Context, tableName string) (string, error) {
+	return f.engines[tableName], nil
+}
+
+func (f *fakeSource) IsTableExists(ctx context.Context, tableName string) (bool, error) {
+	_, ok := f.engines[tableName]
+	return ok, nil
+}
+
+func (f *fakeSource) GetCreateTableQuery(ctx context.Context, tableName string) (string, error) {
+	return f.creates[tableName], nil
+}
+
+func (f *fakeSource) GetClusters(ctx context.Context) ([]string, error) {
+	return f.clusters, nil
+}
+
+// columns returns columns of type UInt64, or of the type after a colon, at
+// their positions in names.
+func columns(names ...string) []models.Column {
+	result := make([]models.Column, len(names))
+	for i, name := range names {
+		name, columnType, ok := strings.Cut(name, ":")
+		if !ok {
+			columnType = "UInt64"
+		}
+		result[i] = models.Column{Name: name, Type: columnType, Position: uint64(i + 1)}
+	}
+	return result
+}
+
+func newTestReplicator(source []models.Column, destination []models.Column) *Replicator {
+	return &Replicator{
+		source:      &fakeSource{database: "src", columns: map[string][]models.Column{"events": source}},
+		destination: &fakeSource{database: "dst", columns: map[string][]models.Column{"events": destination}},
+		logger:      zap.NewNop(),
+	}
+}
+
+func TestSchemaChanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      []models.Column
+		destination []models.Column
+		want        []models.SchemaChange
+	}{
+		{
+			name:        "unchanged",
+			source:      columns("id", "name:String"),
+			destination: columns("id", "name:String"),
+		},
+		{
+			name:        "added at the end",
+			source:      columns("id", "name:String"),
+			destination: columns("id"),
+			want: []models.SchemaChange{{
+				Kind:      models.ChangeAdded,
+				Column:    "name",
+				Type:      "String",
+				Statement: "ALTER TABLE dst.events ADD COLUMN `name` String AFTER `id`",
+			}},
+		},
+		{
+			name:        "added first",
+			source:      columns("id", "name:String"),
+			destination: columns("name:String"),
+			want: []models.SchemaChange{{
+				Kind:      models.ChangeAdded,
+				Column:    "id",
+				Type:      "UInt64",
+				Statement: "ALTER TABLE dst.events ADD COLUMN `id` UInt64 FIRST",
+			}},
+		},
+		{
+			name:        "dropped",
+			source:      columns("id"),
+			destination: columns("id", "name:String"),
+			want: []models.SchemaChange{{
+				Kind:      models.ChangeDropped,
+				Column:    "name",
+				Type:      "String",
+				Statement: "ALTER TABLE dst.events DROP COLUMN `name`",
+			}},
+		},
+		{
+			name:        "retyped",
+			source:      columns("id", "name:LowCardinality(String)"),
+			destination: columns("id", "name:String"),
+			want: []models.SchemaChange{{
+				Kind:         models.ChangeRetyped,
+				Column:       "name",
+				Type:         "LowCardinality(String)",
+				PreviousType: "String",
+				Statement:    "ALTER TABLE dst.events MODIFY COLUMN `name` LowCardinality(String)",
+			}},
+		},
+		{
+			name:        "renamed before the same column",
+			source:      columns("id", "user_id", "name:String"),
+			destination: columns("id", "uid", "name:String"),
+			want: []models.SchemaChange{{
+				Kind:         models.ChangeRenamed,
+				Column:       "user_id",
+				PreviousName: "uid",
+				Type:         "UInt64",
+				Statement:    "ALTER TABLE dst.events RENAME COLUMN `uid` TO `user_id`",
+			}},
+		},
+		{
+			name:        "dropped and added at the end",
+			source:      columns("id", "clicks"),
+			destination: columns("id", "views"),
+			want: []models.SchemaChange{
+				{
+					Kind:      models.ChangeAdded,
+					Column:    "clicks",
+					Type:      "UInt64",
+					Statement: "ALTER TABLE dst.events ADD COLUMN `clicks` UInt64 AFTER `id`",
+				},
+				{
+					Kind:      models.ChangeDropped,
+					Column:    "views",
+					Type:      "UInt64",
+					Statement: "ALTER TABLE dst.events DROP COLUMN `views`",
+				},
+			},
+		},
+		{
+			name:        "different type at the same position",
+			source:      columns("id", "user_id:String", "name:String"),
+			destination: columns("id", "uid", "name:String"),
+			want: []models.SchemaChange{
+				{
+					Kind:      models.ChangeAdded,
+					Column:    "user_id",
+					Type:      "String",
+					Statement: "ALTER TABLE dst.events ADD COLUMN `user_id` String AFTER `id`",
+				},
+				{
+					Kind:      models.ChangeDropped,
+					Column:    "uid",
+					Type:      "UInt64",
+					Statement: "ALTER TABLE dst.events DROP COLUMN `uid`",
+				},
+			},
+		},
+		{
+			name:        "different column after it",
+			source:      columns("id", "user_id", "name:String"),
+			destination: columns("id", "uid", "title:String"),
+			want: []models.SchemaChange{
+				{
+					Kind:      models.ChangeAdded,
+					Column:    "user_id",
+					Type:      "UInt64",
+					Statement: "ALTER TABLE dst.events ADD COLUMN `user_id` UInt64 AFTER `id`",
+				},
+				{
+					Kind:      models.ChangeAdded,
+					Column:    "name",
+					Type:      "String",
+					Statement: "ALTER TABLE dst.events ADD COLUMN `name` String AFTER `user_id`",
+				},
+				{
+					Kind:      models.ChangeDropped,
+					Column:    "uid",
+					Type:      "UInt64",
+					Statement: "ALTER TABLE dst.events DROP COLUMN `uid`",
+				},
+				{
+					Kind:      models.ChangeDropped,
+					Column:    "title",
+					Type:      "String",
+					Statement: "ALTER TABLE dst.events DROP COLUMN `title`",
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := newTestReplicator(test.source, test.destination)
+			got, err := n.schemaChanges(context.Background(), "events", "events")
+			if err != nil {
+				t.Fatalf("schemaChanges() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("schemaChanges() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCommonColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  []models.Column
+		changes []models.SchemaChange
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "no changes",
+			source: columns("id", "name"),
+		},
+		{
+			name:    "dropped columns keep every source column",
+			source:  columns("id", "name"),
+			changes: []models.SchemaChange{{Kind: models.ChangeDropped, Column: "title"}},
+		},
+		{
+			name:   "added, renamed and retyped columns are left out",
+			source: columns("id", "user_id", "name", "clicks", "views"),
+			changes: []models.SchemaChange{
+				{Kind: models.ChangeRenamed, Column: "user_id", PreviousName: "uid"},
+				{Kind: models.ChangeRetyped, Column: "name"},
+				{Kind: models.ChangeAdded, Column: "clicks"},
+				{Kind: models.ChangeDropped, Column: "title"},
+			},
+			want: []string{"id", "views"},
+		},
+		{
+			name:    "no column left",
+			source:  columns("id"),
+			changes: []models.SchemaChange{{Kind: models.ChangeRetyped, Column: "id"}},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := newTestReplicator(test.source, nil)
+			got, err := n.commonColumns(context.Background(), "events", test.changes)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("commonColumns() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("commonColumns() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("commonColumns() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
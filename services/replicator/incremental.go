@@ -0,0 +1,164 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// incrementalRange is the slice of an incremental table a run copies: the
+// rows past the stored watermark, up to the cursor maximum seen when the run
+// started.
+type incrementalRange struct {
+	key       string
+	watermark string
+	found     bool
+	upper     string
+	lookback  time.Duration
+	// lower selects the rows past the watermark (minus the lookback) and is
+	// empty on the first run; upper caps the copy at the captured maximum.
+	lowerCondition string
+	upperCondition string
+}
+
+// upToDate reports whether nothing was added since the last run.
+func (r incrementalRange) upToDate() bool {
+	return r.found && r.watermark == r.upper && r.lookback == 0
+}
+
+// expression returns the boolean expression selecting the rows of the range.
+func (r incrementalRange) expression() string {
+	if r.lowerCondition == "" {
+		return r.upperCondition
+	}
+	return r.upperCondition + " AND " + r.lowerCondition
+}
+
+func (n *Replicator) incrementalRange(ctx context.Context, table string, config models.TableConfig) (incrementalRange, error) {
+	if n.watermarks == nil {
+		return incrementalRange{}, fmt.Errorf("table %s has a cursor column but no watermark store is configured", table)
+	}
+	cursorType, err := n.cursorType(ctx, table, config.CursorColumn)
+	if err != nil {
+		return incrementalRange{}, err
+	}
+	r := incrementalRange{
+		key:      tableKey(n.destination.Database(), n.target(table)),
+		lookback: time.Duration(config.Lookback),
+	}
+	if r.lookback > 0 && !isTemporalType(cursorType) {
+		return r, fmt.Errorf("lookback requires a Date or DateTime cursor column, %s is %s", config.CursorColumn, cursorType)
+	}
+
+	r.watermark, r.found, err = n.watermarks.Get(ctx, r.key)
+	if err != nil {
+		return r, fmt.Errorf("reading watermark: %w", err)
+	}
+	// Capture the upper bound before copying so rows inserted during the copy
+	// are picked up by the next run instead of being skipped.
+	r.upper, err = n.source.GetMaxValue(ctx, table, config.CursorColumn)
+	if err != nil {
+		return r, fmt.Errorf("reading cursor upper bound: %w", err)
+	}
+
+	column := quoteIdentifier(config.CursorColumn)
+	r.upperCondition = fmt.Sprintf("%s <= %s", column, castLiteral(r.upper, cursorType))
+	if r.found {
+		lower := castLiteral(r.watermark, cursorType)
+		if r.lookback > 0 {
+			lower = fmt.Sprintf("%s - INTERVAL %d SECOND", lower, int64(r.lookback.Seconds()))
+		}
+		r.lowerCondition = fmt.Sprintf("%s > %s", column, lower)
+	}
+	return r, nil
+}
+
+// replicateIncremental copies the rows of table whose cursor column is past
+// the stored watermark and advances the watermark once they are inserted.
+func (n *Replicator) replicateIncremental(ctx context.Context, table string, config models.TableConfig) error {
+	r, err := n.incrementalRange(ctx, table, config)
+	if err != nil {
+		return err
+	}
+	if r.upToDate() {
+		n.logger.Info("Skipping the table since it is up to date", zap.String("table", table), zap.String("watermark", r.watermark))
+		return nil
+	}
+
+	// Rows past the lower bound were either never copied or belong to the
+	// lookback window, so an interrupted attempt is discarded by deleting them.
+	discard := func(ctx context.Context) error {
+		if r.lowerCondition == "" {
+			return n.destination.TruncateTable(ctx, n.target(table))
+		}
+		return n.destination.DeleteRows(ctx, n.target(table), r.lowerCondition)
+	}
+	condition := "WHERE " + r.expression()
+	err = n.copyChunk(ctx, table, "incremental", discard, func(ctx context.Context) error {
+		if r.found && r.lookback > 0 {
+			window := r.lowerCondition + " AND " + r.upperCondition
+			n.logger.Info("Reloading lookback window", zap.String("table", table), zap.String("condition", window))
+			if err := n.destination.DeleteRows(ctx, n.target(table), window); err != nil {
+				return fmt.Errorf("clearing lookback window: %w", err)
+			}
+		}
+		n.logger.Info("Copying rows past watermark", zap.String("table", table), zap.String("condition", condition))
+		if err := n.copyRows(ctx, table, n.target(table), condition); err != nil {
+			return err
+		}
+		if err := n.watermarks.Set(ctx, r.key, r.upper); err != nil {
+			return fmt.Errorf("saving watermark: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	n.logger.Info("Successfully Replicated "+table, zap.String("watermark", r.upper))
+	return nil
+}
+
+func (n *Replicator) cursorType(ctx context.Context, table string, cursorColumn string) (string, error) {
+	columns, err := n.source.GetColumns(ctx, table)
+	if err != nil {
+		return "", fmt.Errorf("fetching columns: %w", err)
+	}
+	for _, column := range columns {
+		if column.Name == cursorColumn {
+			return column.Type, nil
+		}
+	}
+	return "", fmt.Errorf("cursor column %s does not exist", cursorColumn)
+}
+
+// isTemporalType reports whether a ClickHouse type is a Date or DateTime,
+// looking through Nullable and LowCardinality wrappers.
+func isTemporalType(columnType string) bool {
+	return strings.HasPrefix(unwrapType(columnType), "Date")
+}
+
+func unwrapType(columnType string) string {
+	for _, wrapper := range []string{"Nullable(", "LowCardinality("} {
+		if strings.HasPrefix(columnType, wrapper) && strings.HasSuffix(columnType, ")") {
+			columnType = unwrapType(columnType[len(wrapper) : len(columnType)-1])
+		}
+	}
+	return columnType
+}
+
+func castLiteral(value string, columnType string) string {
+	return fmt.Sprintf("CAST(%s, %s)", quoteString(value), quoteString(columnType))
+}
+
+func quoteString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
@@ -0,0 +1,119 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// Job replicates the databases of several replicators, one per database
+// pair, on one shared pool of workers and reports them together.
+type Job struct {
+	logger      *zap.Logger
+	replicators []*Replicator
+	concurrency int
+	strict      bool
+}
+
+// NewJob returns a job running replicators on concurrency workers. The
+// replicators share the source query limit of the first one that has one.
+// A strict job returns an error when any table of any database failed.
+func NewJob(logger *zap.Logger, concurrency int, strict bool, replicators ...*Replicator) *Job {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var sourceSlots chan struct{}
+	for _, r := range replicators {
+		if r.sourceSlots != nil {
+			sourceSlots = r.sourceSlots
+			break
+		}
+	}
+	for _, r := range replicators {
+		r.sourceSlots = sourceSlots
+	}
+	return &Job{logger: logger, replicators: replicators, concurrency: concurrency, strict: strict}
+}
+
+// Run prepares every database, then replicates all their tables on the shared
+// pool and creates the views, materialized views and dictionaries of each
+// database in dependency order. A database whose tables cannot be listed stops the job before
+// anything is copied. When ctx is cancelled the partial result is returned
+// together with ctx.Err().
+func (j *Job) Run(ctx context.Context) (*models.JobResult, error) {
+	result := &models.JobResult{
+		StartedAt: time.Now(),
+		Databases: make([]models.ReplicationResult, len(j.replicators)),
+	}
+	var items []work
+	objects := make([][]string, len(j.replicators))
+	for i, r := range j.replicators {
+		tables, databaseObjects, err := r.prepare(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("database %s: %w", r.source.Database(), err)
+		}
+		result.Databases[i] = models.ReplicationResult{
+			SourceDatabase:      r.source.Database(),
+			DestinationDatabase: r.destination.Database(),
+			StartedAt:           result.StartedAt,
+		}
+		for _, table := range tables {
+			items = append(items, work{replicator: r, table: table})
+		}
+		objects[i] = databaseObjects
+	}
+	total := len(items)
+	for _, databaseObjects := range objects {
+		total += len(databaseObjects)
+	}
+
+	j.logger.Info("Replicating tables", zap.Int("databases", len(j.replicators)), zap.Int("tables", total), zap.Int("concurrency", j.concurrency))
+	tableResults := replicateTables(ctx, j.concurrency, items)
+	objectResults := make([][]models.TableResult, len(j.replicators))
+	for i, r := range j.replicators {
+		objectResults[i] = r.createObjects(ctx, objects[i])
+	}
+	result.FinishedAt = time.Now()
+	result.Duration = models.Duration(result.FinishedAt.Sub(result.StartedAt))
+	for i, r := range j.replicators {
+		database := &result.Databases[i]
+		for index, item := range items {
+			if item.replicator == r {
+				database.Tables = append(database.Tables, tableResults[index])
+			}
+		}
+		database.Tables = append(database.Tables, objectResults[i]...)
+		database.FinishedAt = result.FinishedAt
+		database.Duration = result.Duration
+	}
+	j.logger.Info("Replication finished",
+		zap.Int("copied", result.Count(models.StatusCopied)+result.Count(models.StatusCreated)),
+		zap.Int("skipped", result.Count(models.StatusSkipped)),
+		zap.Int("failed", result.Count(models.StatusFailed)))
+
+	if err := ctx.Err(); err != nil {
+		j.logger.Warn("Replication stopped before all tables were copied", zap.Error(err))
+		return result, err
+	}
+
+	failed := result.Count(models.StatusFailed)
+	// Checkpoints only matter for resuming; once every table has been
+	// replicated the next run starts afresh.
+	if failed == 0 {
+		for _, r := range j.replicators {
+			if r.checkpoints == nil {
+				continue
+			}
+			if err := r.checkpoints.Reset(ctx); err != nil {
+				j.logger.Error("Error clearing checkpoints", zap.Error(err))
+			}
+		}
+	}
+	if j.strict && failed > 0 {
+		return result, fmt.Errorf("%d of %d tables failed: %w", failed, total, result.Err())
+	}
+	return result, nil
+}
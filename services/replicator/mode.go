@@ -0,0 +1,181 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// stagingSuffix names the table a full-refresh-swap loads before exchanging
+// it with the destination table.
+const stagingSuffix = "__staging"
+
+// modeReasons explains the plan of the modes that copy every row.
+var modeReasons = map[models.LoadMode]string{
+	models.LoadTruncateReload:  "truncate and reload every row",
+	models.LoadFullRefreshSwap: "load every row into a staging table, then exchange it",
+	models.LoadUpsert:          "insert every row, replacing older versions",
+}
+
+// loadTable copies the rows of table the way its load mode asks for.
+func (n *Replicator) loadTable(ctx context.Context, table string, plan tablePlan) error {
+	switch plan.Mode {
+	case models.LoadTruncateReload:
+		return n.truncateAndReload(ctx, table, plan)
+	case models.LoadFullRefreshSwap:
+		return n.refreshAndSwap(ctx, table, plan)
+	case models.LoadUpsert:
+		return n.upsert(ctx, table, plan)
+	default:
+		return n.copyTable(ctx, table, plan)
+	}
+}
+
+// truncateAndReload empties the destination table and copies every row.
+// Readers see the table empty or partly loaded until the copy finishes.
+func (n *Replicator) truncateAndReload(ctx context.Context, table string, plan tablePlan) error {
+	target := n.target(table)
+	truncate := func(ctx context.Context) error {
+		if err := n.destination.TruncateTable(ctx, target); err != nil {
+			return fmt.Errorf("truncating table: %w", err)
+		}
+		return nil
+	}
+	err := n.retry(ctx, func() error {
+		return n.copyChunk(ctx, table, "reload", truncate, func(ctx context.Context) error {
+			if err := truncate(ctx); err != nil {
+				return err
+			}
+			n.logger.Info("Reloading table", zap.String("table", table), zap.Uint64("rows", plan.sourceRows))
+			return n.copyAll(ctx, table, target, plan.sourceRows)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	n.logger.Info("Successfully Replicated " + table)
+	return nil
+}
+
+// refreshAndSwap copies every row into a fresh staging table and exchanges
+// it with the destination table, then drops the staging table, which by then
+// holds the previous rows.
+func (n *Replicator) refreshAndSwap(ctx context.Context, table string, plan tablePlan) error {
+	target := n.target(table)
+	staging := target + stagingSuffix
+	dropStaging := func(ctx context.Context) error {
+		return n.destination.ExecuteDDL(ctx, n.destination.DropTableQuery(staging))
+	}
+	err := n.retry(ctx, func() error {
+		return n.copyChunk(ctx, table, "swap", dropStaging, func(ctx context.Context) error {
+			if err := dropStaging(ctx); err != nil {
+				return fmt.Errorf("dropping staging table: %w", err)
+			}
+			if err := n.cloner.CloneTable(ctx, table, staging); err != nil {
+				return fmt.Errorf("creating staging table: %w", err)
+			}
+			n.logger.Info("Loading staging table", zap.String("table", table), zap.String("staging", staging), zap.Uint64("rows", plan.sourceRows))
+			if err := n.copyAll(ctx, table, staging, plan.sourceRows); err != nil {
+				return err
+			}
+			n.logger.Info("Exchanging tables", zap.String("table", target), zap.String("staging", staging))
+			if err := n.destination.ExecuteDDL(ctx, n.destination.ExchangeTablesQuery(target, staging)); err != nil {
+				return fmt.Errorf("exchanging tables: %w", err)
+			}
+			if err := dropStaging(ctx); err != nil {
+				return fmt.Errorf("dropping previous table: %w", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	n.logger.Info("Successfully Replicated " + table)
+	return nil
+}
+
+// upsert inserts the rows past the watermark, or every row without a cursor
+// column, into a ReplacingMergeTree table. Rows copied twice collapse into
+// one on merge, so an attempt that failed halfway is simply repeated.
+func (n *Replicator) upsert(ctx context.Context, table string, plan tablePlan) error {
+	if plan.config.CursorColumn != "" {
+		return n.replicateIncremental(ctx, table, plan.config)
+	}
+	target := n.target(table)
+	discard := func(ctx context.Context) error { return nil }
+	err := n.retry(ctx, func() error {
+		return n.copyChunk(ctx, table, "upsert", discard, func(ctx context.Context) error {
+			n.logger.Info("Upserting table", zap.String("table", table), zap.Uint64("rows", plan.sourceRows))
+			return n.copyAll(ctx, table, target, plan.sourceRows)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	n.logger.Info("Successfully Replicated " + table)
+	return nil
+}
+
+// copyAll copies every row of table into destinationTable, in sorting key
+// ranges of about n.chunkRows rows when the table holds more.
+func (n *Replicator) copyAll(ctx context.Context, table string, destinationTable string, rows uint64) error {
+	if rows == 0 {
+		return nil
+	}
+	if n.chunkRows == 0 || rows <= n.chunkRows {
+		return n.copyRows(ctx, table, destinationTable, "")
+	}
+	chunks, err := n.source.PlanChunks(ctx, table, "", n.chunkRows)
+	if err != nil {
+		return fmt.Errorf("planning chunks: %w", err)
+	}
+	for _, chunk := range chunks {
+		condition := ""
+		if chunk.Condition != "" {
+			condition = "WHERE " + chunk.Condition
+		}
+		n.logger.Info("Copying chunk", zap.String("table", table), zap.String("chunk", chunk.ID), zap.String("destination", destinationTable))
+		if err := n.copyRows(ctx, table, destinationTable, condition); err != nil {
+			return fmt.Errorf("chunk %s: %w", chunk.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkUpsertEngine makes sure the rows of an upsert land in a
+// ReplacingMergeTree table: the existing destination table, or else the
+// source table it will be cloned from.
+func (n *Replicator) checkUpsertEngine(ctx context.Context, plan tablePlan, tableExists bool) error {
+	engine := plan.Engine
+	if tableExists {
+		var err error
+		engine, err = n.destination.GetTableEngine(ctx, plan.Destination)
+		if err != nil {
+			return fmt.Errorf("fetching destination engine: %w", err)
+		}
+	}
+	if !strings.HasSuffix(engine, "ReplacingMergeTree") {
+		return fmt.Errorf("load mode %s needs a ReplacingMergeTree table, %s is %s", models.LoadUpsert, plan.Destination, engine)
+	}
+	return nil
+}
+
+// swapQueries returns the statements a full-refresh-swap of table runs
+// around loading the staging table.
+func (n *Replicator) swapQueries(ctx context.Context, table string, destinationTable string) ([]string, error) {
+	staging := destinationTable + stagingSuffix
+	create, err := n.cloner.CreateTableQuery(ctx, table, staging)
+	if err != nil {
+		return nil, fmt.Errorf("building staging create query: %w", err)
+	}
+	return []string{
+		n.destination.DropTableQuery(staging),
+		create,
+		n.destination.ExchangeTablesQuery(destinationTable, staging),
+		n.destination.DropTableQuery(staging),
+	}, nil
+}
@@ -0,0 +1,204 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// innerTablePrefixes start the names of the tables holding the rows of
+// materialized views without a TO clause. They are created with their view.
+var innerTablePrefixes = []string{".inner.", ".inner_id."}
+
+func isInnerTable(name string) bool {
+	for _, prefix := range innerTablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectKind tells the kind of object from its engine. Anything that is not a
+// view or dictionary is a table, copyable or not.
+func objectKind(engine string) models.ObjectKind {
+	switch engine {
+	case "View":
+		return models.KindView
+	case "MaterializedView":
+		return models.KindMaterializedView
+	case "Dictionary":
+		return models.KindDictionary
+	default:
+		return models.KindTable
+	}
+}
+
+// kindRank orders objects with no dependency between them: dictionaries may
+// be read by views, and materialized views come last.
+var kindRank = map[models.ObjectKind]int{
+	models.KindTable:            0,
+	models.KindDictionary:       1,
+	models.KindView:             2,
+	models.KindMaterializedView: 3,
+}
+
+// splitObjects separates the selected tables from the views, materialized
+// views and dictionaries, which are returned in dependency order.
+func (n *Replicator) splitObjects(ctx context.Context, selected []string) ([]string, []string, error) {
+	objects, err := n.source.GetObjects(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching objects: %w", err)
+	}
+	byName := make(map[string]models.DatabaseObject, len(objects))
+	for _, object := range objects {
+		byName[object.Name] = object
+	}
+	var tables, schemaObjects []string
+	for _, name := range selected {
+		if objectKind(byName[name].Engine) == models.KindTable {
+			tables = append(tables, name)
+		} else {
+			schemaObjects = append(schemaObjects, name)
+		}
+	}
+	return tables, n.sortObjects(schemaObjects, byName), nil
+}
+
+// sortObjects orders names topologically along the dependencies reported in
+// system.tables, so an object is created after the ones it reads from. Ties
+// keep the kind order and then the name order. Objects caught in a cycle are
+// appended at the end in name order.
+func (n *Replicator) sortObjects(names []string, objects map[string]models.DatabaseObject) []string {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	pending := make(map[string]int, len(names))
+	dependents := make(map[string][]string, len(names))
+	for _, name := range names {
+		for _, dependent := range objects[name].Dependents {
+			if selected[dependent] && dependent != name {
+				dependents[name] = append(dependents[name], dependent)
+				pending[dependent]++
+			}
+		}
+	}
+	less := func(a string, b string) bool {
+		rankA, rankB := kindRank[objectKind(objects[a].Engine)], kindRank[objectKind(objects[b].Engine)]
+		if rankA != rankB {
+			return rankA < rankB
+		}
+		return a < b
+	}
+
+	var ready, ordered []string
+	for _, name := range names {
+		if pending[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return less(ready[i], ready[j]) })
+		next := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, next)
+		for _, dependent := range dependents[next] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if len(ordered) < len(names) {
+		var cycle []string
+		for _, name := range names {
+			if pending[name] > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Slice(cycle, func(i, j int) bool { return less(cycle[i], cycle[j]) })
+		n.logger.Warn("Objects depend on each other in a cycle, creating them in name order", zap.Strings("objects", cycle))
+		ordered = append(ordered, cycle...)
+	}
+	return ordered
+}
+
+// planObject plans a view, materialized view or dictionary: it is created
+// from its DDL when missing on the destination and left alone otherwise. Its
+// rows, if any, are never copied.
+func (n *Replicator) planObject(ctx context.Context, table string, plan tablePlan) (tablePlan, error) {
+	exists, err := n.destination.IsTableExists(ctx, plan.Destination)
+	if err != nil {
+		return plan, fmt.Errorf("checking if %s exists: %w", plan.Kind, err)
+	}
+	if exists {
+		plan.Action = models.ActionSkip
+		plan.Reason = fmt.Sprintf("%s exists on destination", plan.Kind)
+		return plan, nil
+	}
+	ddl, err := n.cloner.CreateObjectQuery(ctx, table, plan.Destination, n.target)
+	if err != nil {
+		return plan, fmt.Errorf("building create query: %w", err)
+	}
+	plan.Action = models.ActionCreate
+	plan.Reason = fmt.Sprintf("%s missing on destination", plan.Kind)
+	plan.DDL = []string{ddl}
+	return plan, nil
+}
+
+// createObject runs the DDL of a planned view, materialized view, dictionary
+// or Distributed table.
+func (n *Replicator) createObject(ctx context.Context, plan tablePlan) error {
+	for _, query := range plan.DDL {
+		n.logger.Info("Creating object", zap.String("table", plan.Table), zap.String("kind", string(plan.Kind)), zap.String("query", query))
+		if err := n.destination.ExecuteDDL(ctx, query); err != nil {
+			return fmt.Errorf("creating %s: %w", plan.Kind, err)
+		}
+	}
+	return nil
+}
+
+// createObjects creates the views, materialized views and dictionaries of a
+// database one after the other, in dependency order, once its tables have
+// been copied. Materialized views are only attached after the copy, so the
+// copied rows do not flow through them into their target tables a second time.
+//
+// system.tables misses some dependencies, such as a view selecting from
+// another view or a dictionary sourced from a view, so objects that fail are
+// tried again after the others, for as long as a pass creates something.
+func (n *Replicator) createObjects(ctx context.Context, objects []string) []models.TableResult {
+	results := make([]models.TableResult, len(objects))
+	pending := make([]int, len(objects))
+	for i := range objects {
+		pending[i] = i
+	}
+	for len(pending) > 0 {
+		var failed []int
+		for _, i := range pending {
+			if ctx.Err() != nil {
+				results[i] = notStarted(ctx, objects[i])
+				continue
+			}
+			results[i] = n.replicateTableResult(ctx, objects[i])
+			if results[i].Status == models.StatusFailed {
+				failed = append(failed, i)
+			}
+		}
+		if len(failed) == len(pending) || ctx.Err() != nil {
+			break
+		}
+		if len(failed) > 0 {
+			names := make([]string, len(failed))
+			for j, i := range failed {
+				names[j] = objects[i]
+			}
+			n.logger.Info("Retrying objects that may depend on objects created since", zap.Strings("objects", names))
+		}
+		pending = failed
+	}
+	return results
+}
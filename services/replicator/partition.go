@@ -0,0 +1,94 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// unpartitionedID is the partition id ClickHouse gives tables without a PARTITION BY.
+const unpartitionedID = "all"
+
+// replicatePartitions copies a table one partition at a time. Partitions whose
+// row count already matches on the destination are skipped; the others are
+// dropped on the destination and copied again, each with its own retries.
+// It returns false when the table is not partitioned and must be copied whole.
+func (n *Replicator) replicatePartitions(ctx context.Context, table string) (bool, error) {
+	sourcePartitions, err := n.source.GetPartitions(ctx, table)
+	if err != nil {
+		return false, fmt.Errorf("fetching source partitions: %w", err)
+	}
+	if len(sourcePartitions) == 0 || (len(sourcePartitions) == 1 && sourcePartitions[0].ID == unpartitionedID) {
+		return false, nil
+	}
+	destinationPartitions, err := n.destination.GetPartitions(ctx, n.target(table))
+	if err != nil {
+		return true, fmt.Errorf("fetching destination partitions: %w", err)
+	}
+	destinationRows := make(map[string]uint64, len(destinationPartitions))
+	for _, partition := range destinationPartitions {
+		destinationRows[partition.ID] = partition.Rows
+	}
+
+	failed := 0
+	for _, partition := range sourcePartitions {
+		if err := n.replicatePartition(ctx, table, partition, destinationRows[partition.ID]); err != nil {
+			n.logger.Error("Error replicating partition", zap.String("table", table), zap.String("partition", partition.ID), zap.Error(err))
+			failed++
+		}
+	}
+	if failed > 0 {
+		return true, fmt.Errorf("%d of %d partitions failed", failed, len(sourcePartitions))
+	}
+	return true, nil
+}
+
+func (n *Replicator) replicatePartition(ctx context.Context, table string, partition models.Partition, destinationRows uint64) error {
+	chunk := "partition:" + partition.ID
+	if destinationRows == partition.Rows {
+		n.logger.Info("Skipping partition since it contains all rows", zap.String("table", table), zap.String("partition", partition.ID))
+		return n.setCheckpoint(ctx, table, chunk, models.CheckpointDone)
+	}
+	drop := func(ctx context.Context) error {
+		return n.destination.DropPartition(ctx, n.target(table), partition.ID)
+	}
+	scope := fmt.Sprintf("_partition_id = %s", quoteString(partition.ID))
+	if n.chunkRows > 0 && partition.Rows > n.chunkRows {
+		return n.replicateChunks(ctx, table, chunk+"/", scope)
+	}
+	return n.retry(ctx, func() error {
+		return n.copyChunk(ctx, table, chunk, drop, func(ctx context.Context) error {
+			if destinationRows > 0 {
+				n.logger.Info("Dropping incomplete partition", zap.String("table", table), zap.String("partition", partition.ID), zap.Uint64("rows", destinationRows))
+				if err := drop(ctx); err != nil {
+					return fmt.Errorf("dropping partition: %w", err)
+				}
+			}
+			n.logger.Info("Copying partition", zap.String("table", table), zap.String("partition", partition.ID), zap.Uint64("rows", partition.Rows))
+			return n.copyRows(ctx, table, n.target(table), "WHERE "+scope)
+		})
+	})
+}
+
+// retry runs fn until it succeeds or n.retries further attempts have failed,
+// waiting a little longer after each failure.
+func (n *Replicator) retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			n.logger.Warn("Retrying", zap.Int("attempt", attempt), zap.Error(err))
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,323 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/engine"
+	"go.uber.org/zap"
+)
+
+// copyableEngines are the engine families whose rows can be read with SELECT
+// and written with INSERT into a clone of the table.
+var copyableEngines = []string{"MergeTree", "Log", "TinyLog", "StripeLog", "Memory"}
+
+// distributedEngine is the engine of tables reading from the tables of a
+// cluster, which are created without copying any rows.
+const distributedEngine = "Distributed"
+
+// IsCopyableEngine reports whether the rows of a table with engine can be copied.
+func IsCopyableEngine(engine string) bool {
+	for _, copyable := range copyableEngines {
+		if strings.HasSuffix(engine, copyable) {
+			return true
+		}
+	}
+	return false
+}
+
+// tablePlan is a TablePlan together with what the replicator learned while
+// building it.
+type tablePlan struct {
+	models.TablePlan
+	config     models.TableConfig
+	sourceRows uint64
+	// wrapper creates the Distributed table over the table, if planned.
+	wrapper string
+}
+
+// Plan works out what ReplicateDatabase would do with every table without
+// writing anything to the destination.
+func (n *Replicator) Plan(ctx context.Context) (*models.ReplicationPlan, error) {
+	selected, excluded, err := n.listTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching tables: %w", err)
+	}
+	tables, objects, err := n.splitObjects(ctx, selected)
+	if err != nil {
+		return nil, err
+	}
+	tables = append(tables, objects...)
+	if err := n.checkCluster(ctx); err != nil {
+		return nil, err
+	}
+
+	plan := &models.ReplicationPlan{
+		SourceDatabase:      n.source.Database(),
+		DestinationDatabase: n.destination.Database(),
+		DDL:                 []string{n.destination.CreateDatabaseQuery()},
+		Excluded:            excluded,
+	}
+	for _, table := range tables {
+		tablePlan, err := n.planTable(ctx, table)
+		if err != nil {
+			return nil, fmt.Errorf("planning table %s: %w", table, err)
+		}
+		if err := n.planWrapper(ctx, &tablePlan); err != nil {
+			return nil, fmt.Errorf("planning table %s: %w", table, err)
+		}
+		// Show the statements as they run on a cluster.
+		for i, query := range tablePlan.DDL {
+			tablePlan.DDL[i] = n.destination.ClusterQuery(query)
+		}
+		plan.Tables = append(plan.Tables, tablePlan.TablePlan)
+	}
+	return plan, nil
+}
+
+// planTable decides how table is replicated. ReplicateDatabase acts on the
+// same decision, so a plan always matches what a run would do.
+func (n *Replicator) planTable(ctx context.Context, table string) (tablePlan, error) {
+	plan := tablePlan{
+		TablePlan: models.TablePlan{Table: table, Destination: n.target(table)},
+		config:    n.tables[table],
+	}
+	plan.Mode = plan.config.Mode
+	switch plan.Mode {
+	case "":
+		plan.Mode = models.LoadAppend
+	case models.LoadAppend, models.LoadTruncateReload, models.LoadFullRefreshSwap, models.LoadUpsert:
+	default:
+		return plan, fmt.Errorf("unknown load mode %q", plan.Mode)
+	}
+	if plan.config.CursorColumn != "" && !plan.Mode.Incremental() {
+		return plan, fmt.Errorf("load mode %s copies every row and cannot be combined with a cursor column", plan.Mode)
+	}
+	engine, err := n.source.GetTableEngine(ctx, table)
+	if err != nil {
+		return plan, fmt.Errorf("fetching engine: %w", err)
+	}
+	plan.Engine = engine
+	plan.Kind = objectKind(engine)
+	if plan.Kind != models.KindTable {
+		plan.Mode = ""
+		return n.planObject(ctx, table, plan)
+	}
+	if engine == distributedEngine {
+		plan.Mode = ""
+		return n.planDistributed(ctx, table, plan)
+	}
+	if !IsCopyableEngine(engine) {
+		plan.Action = models.ActionUnsupported
+		plan.Reason = fmt.Sprintf("%s objects are not copied", engine)
+		return plan, nil
+	}
+
+	tableExists, err := n.destination.IsTableExists(ctx, plan.Destination)
+	if err != nil {
+		return plan, fmt.Errorf("checking if table exists: %w", err)
+	}
+	plan.sourceRows, err = n.source.GetRowCount(ctx, table)
+	if err != nil {
+		return plan, fmt.Errorf("fetching source row count: %w", err)
+	}
+	if plan.Mode == models.LoadUpsert {
+		if err := n.checkUpsertEngine(ctx, plan, tableExists); err != nil {
+			return plan, err
+		}
+	}
+	// An empty source still empties the destination when every row is
+	// reloaded, and is created when missing so views can read from it.
+	if plan.sourceRows == 0 && plan.Mode.Incremental() && tableExists {
+		plan.Action = models.ActionSkip
+		plan.Reason = "source table is empty"
+		return plan, nil
+	}
+
+	if plan.sourceRows == 0 && plan.Mode.Incremental() {
+		plan.Action = models.ActionSkip
+	} else if plan.config.CursorColumn != "" {
+		r, err := n.incrementalRange(ctx, table, plan.config)
+		if err != nil {
+			return plan, err
+		}
+		if r.upToDate() {
+			plan.Action = models.ActionSkip
+			plan.Reason = "up to date at watermark " + r.watermark
+			return plan, nil
+		}
+		plan.Action = models.ActionIncrementalCopy
+		plan.Reason = "rows matching " + r.expression()
+		if err := n.estimateIncremental(ctx, &plan, r); err != nil {
+			return plan, err
+		}
+	} else if plan.Mode != models.LoadAppend {
+		plan.Action = models.ActionFullCopy
+		plan.Reason = modeReasons[plan.Mode]
+		if err := n.estimateFullCopy(ctx, &plan, false); err != nil {
+			return plan, err
+		}
+	} else {
+		if tableExists {
+			destinationRows, err := n.destination.GetRowCount(ctx, plan.Destination)
+			if err != nil {
+				return plan, fmt.Errorf("fetching destination row count: %w", err)
+			}
+			if destinationRows == plan.sourceRows {
+				plan.Action = models.ActionSkip
+				plan.Reason = "destination contains all rows"
+				return plan, nil
+			}
+		}
+		plan.Action = models.ActionFullCopy
+		if err := n.estimateFullCopy(ctx, &plan, tableExists); err != nil {
+			return plan, err
+		}
+	}
+
+	// A swap reloads into a fresh clone of the source table, so it follows
+	// source columns on its own.
+	if tableExists && plan.Mode != models.LoadFullRefreshSwap {
+		plan.SchemaChanges, err = n.schemaChanges(ctx, table, plan.Destination)
+		if err != nil {
+			return plan, err
+		}
+		if n.schema == models.SchemaApply {
+			for _, change := range plan.SchemaChanges {
+				plan.DDL = append(plan.DDL, change.Statement)
+			}
+		}
+	}
+	if !tableExists {
+		ddl, err := n.cloner.CreateTableQuery(ctx, table, plan.Destination)
+		if err != nil {
+			return plan, fmt.Errorf("building create query: %w", err)
+		}
+		plan.Reason = fmt.Sprintf("missing on destination, then %s", plan.Action)
+		if plan.Action == models.ActionSkip {
+			plan.Reason = "missing on destination, source table is empty"
+		}
+		plan.Action = models.ActionCreate
+		plan.DDL = append(plan.DDL, ddl)
+		if err := n.checkEngine(ctx, &plan, ddl); err != nil {
+			return plan, err
+		}
+	}
+	if plan.Mode == models.LoadFullRefreshSwap {
+		ddl, err := n.swapQueries(ctx, table, plan.Destination)
+		if err != nil {
+			return plan, err
+		}
+		plan.DDL = append(plan.DDL, ddl...)
+	}
+	return plan, nil
+}
+
+// planDistributed plans a Distributed table. It holds no rows of its own, so
+// it is only created from its translated DDL when missing on the destination.
+func (n *Replicator) planDistributed(ctx context.Context, table string, plan tablePlan) (tablePlan, error) {
+	exists, err := n.destination.IsTableExists(ctx, plan.Destination)
+	if err != nil {
+		return plan, fmt.Errorf("checking if table exists: %w", err)
+	}
+	if exists {
+		plan.Action = models.ActionSkip
+		plan.Reason = "distributed table exists on destination"
+		return plan, nil
+	}
+	ddl, err := n.cloner.CreateTableQuery(ctx, table, plan.Destination)
+	if err != nil {
+		return plan, fmt.Errorf("building create query: %w", err)
+	}
+	plan.Action = models.ActionCreate
+	plan.Reason = "distributed table missing on destination, its rows are not copied"
+	plan.DDL = []string{ddl}
+	if err := n.checkEngine(ctx, &plan, ddl); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// checkEngine records the engine ddl creates the table with when the engine
+// translation changed it, and makes sure a Distributed engine reads from a
+// cluster the destination knows, so a wrong rule shows in the plan rather
+// than halfway through a run.
+func (n *Replicator) checkEngine(ctx context.Context, plan *tablePlan, ddl string) error {
+	created, ok := engine.Parse(ddl)
+	if !ok {
+		return nil
+	}
+	if created.Name != plan.Engine {
+		plan.DestinationEngine = created.Name
+	}
+	cluster := created.Cluster()
+	if cluster == "" || strings.Contains(cluster, "{") {
+		return nil
+	}
+	clusters, err := n.destination.GetClusters(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching destination clusters: %w", err)
+	}
+	for _, known := range clusters {
+		if known == cluster {
+			return nil
+		}
+	}
+	return fmt.Errorf("distributed table reads from cluster %s, which the destination does not define", cluster)
+}
+
+// estimateFullCopy counts the rows and bytes of the source partitions whose
+// row count differs on the destination.
+func (n *Replicator) estimateFullCopy(ctx context.Context, plan *tablePlan, tableExists bool) error {
+	sourcePartitions, err := n.source.GetPartitions(ctx, plan.Table)
+	if err != nil {
+		return fmt.Errorf("fetching source partitions: %w", err)
+	}
+	destinationRows := make(map[string]uint64)
+	if tableExists {
+		destinationPartitions, err := n.destination.GetPartitions(ctx, plan.Destination)
+		if err != nil {
+			return fmt.Errorf("fetching destination partitions: %w", err)
+		}
+		for _, partition := range destinationPartitions {
+			destinationRows[partition.ID] = partition.Rows
+		}
+	}
+	for _, partition := range sourcePartitions {
+		if partition.ID != unpartitionedID && destinationRows[partition.ID] == partition.Rows {
+			continue
+		}
+		plan.EstimatedRows += partition.Rows
+		plan.EstimatedBytes += partition.Bytes
+	}
+	return nil
+}
+
+// estimateIncremental counts the rows in the incremental range and scales the
+// table's bytes on disk accordingly.
+func (n *Replicator) estimateIncremental(ctx context.Context, plan *tablePlan, r incrementalRange) error {
+	rows, err := n.source.GetRowCountWhere(ctx, plan.Table, r.expression())
+	if err != nil {
+		return fmt.Errorf("counting rows past watermark: %w", err)
+	}
+	partitions, err := n.source.GetPartitions(ctx, plan.Table)
+	if err != nil {
+		return fmt.Errorf("fetching source partitions: %w", err)
+	}
+	var totalRows, totalBytes uint64
+	for _, partition := range partitions {
+		totalRows += partition.Rows
+		totalBytes += partition.Bytes
+	}
+	plan.EstimatedRows = rows
+	if totalRows > 0 {
+		plan.EstimatedBytes = uint64(float64(totalBytes) * float64(rows) / float64(totalRows))
+	}
+	return nil
+}
+
+func (n *Replicator) logSkip(plan tablePlan) {
+	n.logger.Info("Skipping table", zap.String("table", plan.Table), zap.String("action", string(plan.Action)), zap.String("reason", plan.Reason))
+}
@@ -0,0 +1,102 @@
+package replicator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/engine"
+	"go.uber.org/zap"
+)
+
+// fakeCloner returns the create queries of its source translated by its
+// translator. Other methods are not implemented and panic.
+type fakeCloner struct {
+	SchemaCloner
+	source     *fakeSource
+	translator *engine.Translator
+}
+
+func (c *fakeCloner) CreateTableQuery(ctx context.Context, sourceTable string, destinationTable string) (string, error) {
+	return c.translator.Translate(c.source.creates[sourceTable])
+}
+
+func TestPlanDistributedTable(t *testing.T) {
+	const create = "CREATE TABLE src.events_all (`id` UInt64) ENGINE = Distributed('production', 'src', 'events', rand())"
+	tests := []struct {
+		name       string
+		rules      []models.EngineRule
+		existing   bool
+		clusters   []string
+		wantAction models.PlanAction
+		wantDDL    []string
+		wantErr    bool
+	}{
+		{
+			name:       "rule points it at the destination cluster",
+			rules:      []models.EngineRule{{Rule: models.RuleDistributedCluster, From: "production", To: "development"}},
+			clusters:   []string{"development"},
+			wantAction: models.ActionCreate,
+			wantDDL:    []string{"CREATE TABLE src.events_all (`id` UInt64) ENGINE = Distributed('development', 'src', 'events', rand())"},
+		},
+		{
+			name:       "without a rule it keeps its cluster",
+			clusters:   []string{"production"},
+			wantAction: models.ActionCreate,
+			wantDDL:    []string{create},
+		},
+		{
+			name:     "cluster missing on the destination",
+			clusters: []string{"development"},
+			wantErr:  true,
+		},
+		{
+			name:       "existing table",
+			existing:   true,
+			wantAction: models.ActionSkip,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			translator, err := engine.New(models.EngineTranslation{Rules: test.rules})
+			if err != nil {
+				t.Fatalf("engine.New() error: %v", err)
+			}
+			source := &fakeSource{
+				database: "src",
+				engines:  map[string]string{"events_all": "Distributed"},
+				creates:  map[string]string{"events_all": create},
+			}
+			destination := &fakeSource{database: "dst", clusters: test.clusters}
+			if test.existing {
+				destination.engines = map[string]string{"events_all": "Distributed"}
+			}
+			n := &Replicator{
+				source:      source,
+				destination: destination,
+				cloner:      &fakeCloner{source: source, translator: translator},
+				logger:      zap.NewNop(),
+			}
+			plan, err := n.planTable(context.Background(), "events_all")
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("planTable() = %+v, want an error", plan.TablePlan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planTable() error: %v", err)
+			}
+			if plan.Action != test.wantAction {
+				t.Errorf("action = %s, want %s", plan.Action, test.wantAction)
+			}
+			if !reflect.DeepEqual(plan.DDL, test.wantDDL) {
+				t.Errorf("DDL = %q, want %q", plan.DDL, test.wantDDL)
+			}
+			if plan.Mode != "" || plan.EstimatedRows != 0 {
+				t.Errorf("distributed table planned with mode %q and %d rows to copy", plan.Mode, plan.EstimatedRows)
+			}
+		})
+	}
+}
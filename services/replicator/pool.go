@@ -0,0 +1,98 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// work is one table of one database of a job.
+type work struct {
+	replicator *Replicator
+	table      string
+}
+
+// replicateTables replicates every item on a pool of concurrency workers and
+// returns the results in the order of items. A failing or panicking table
+// never stops the others. Once ctx is cancelled the remaining tables are not
+// started and are reported as failed.
+func replicateTables(ctx context.Context, concurrency int, items []work) []models.TableResult {
+	workers := concurrency
+	if workers > len(items) {
+		workers = len(items)
+	}
+	results := make([]models.TableResult, len(items))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				results[index] = items[index].replicator.replicateTableResult(ctx, items[index].table)
+			}
+		}()
+	}
+	dispatched := 0
+dispatch:
+	for ; dispatched < len(items); dispatched++ {
+		select {
+		case queue <- dispatched:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+	for index := dispatched; index < len(items); index++ {
+		results[index] = notStarted(ctx, items[index].table)
+	}
+	return results
+}
+
+func (n *Replicator) replicateIsolated(ctx context.Context, table string, result *models.TableResult) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while replicating table: %v", r)
+		}
+	}()
+	return n.replicateTable(ctx, table, result)
+}
+
+// orderTables sorts tables by their size on the source according to n.order.
+func (n *Replicator) orderTables(ctx context.Context, tables []string) []string {
+	if n.order == models.OrderByName {
+		return tables
+	}
+	sizes, err := n.source.GetTableSizes(ctx)
+	if err != nil {
+		n.logger.Warn("Could not fetch table sizes, keeping source order", zap.Error(err))
+		return tables
+	}
+	ordered := append([]string(nil), tables...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if n.order == models.OrderSmallestFirst {
+			return sizes[ordered[i]] < sizes[ordered[j]]
+		}
+		return sizes[ordered[i]] > sizes[ordered[j]]
+	})
+	return ordered
+}
+
+// acquireSource blocks until a source query slot is free. The returned
+// function releases the slot.
+func (n *Replicator) acquireSource(ctx context.Context) (func(), error) {
+	if n.sourceSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case n.sourceSlots <- struct{}{}:
+		return func() { <-n.sourceSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -2,8 +2,12 @@ package replicator
 
 import (
 	"context"
+	"fmt"
 
-	"github.com/prasannakumar414/click-replicator/utils"
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/filter"
+	"github.com/prasannakumar414/click-replicator/services/mapping"
+	"github.com/prasannakumar414/click-replicator/services/secret"
 	"go.uber.org/zap"
 )
 
@@ -17,8 +21,31 @@ type DataSource interface {
 	CreateTableFromJSONFile(ctx context.Context, tableName string, orderBy string, fileName string) error
 	CreateDatabase(ctx context.Context) error
 	OptimizeTable(ctx context.Context, tableName string) error
-	GetRowJsonsWithLimit(ctx context.Context, tableName string, format string, limit int, offset int) ([]string, error)
+	GetRowJsonsForChunk(ctx context.Context, tableName string, format string, chunk models.Chunk) ([]string, error)
 	CreateTableFromJSONData(ctx context.Context, tableName string, orderBy string, rows []string) error
+	Database() string
+	TruncateTable(ctx context.Context, tableName string) error
+	GetTableSizes(ctx context.Context) (map[string]uint64, error)
+	GetPartitions(ctx context.Context, tableName string) ([]models.Partition, error)
+	DropPartition(ctx context.Context, tableName string, partitionID string) error
+	PlanChunks(ctx context.Context, tableName string, scope string, rowsPerChunk uint64) ([]models.Chunk, error)
+	GetRowCountWhere(ctx context.Context, tableName string, condition string) (uint64, error)
+	GetPartitionChecksums(ctx context.Context, tableName string) (map[string]models.Checksum, error)
+	GetTableEngine(ctx context.Context, tableName string) (string, error)
+	CreateDatabaseQuery() string
+	DropTableQuery(tableName string) string
+	ExchangeTablesQuery(tableName string, otherTableName string) string
+	GetCreateTableQuery(ctx context.Context, tableName string) (string, error)
+	ExecuteDDL(ctx context.Context, query string) error
+	GetColumns(ctx context.Context, tableName string) ([]models.Column, error)
+	GetTableSchema(ctx context.Context, tableName string) (models.TableSchema, error)
+	GetObjects(ctx context.Context) ([]models.DatabaseObject, error)
+	GetClusters(ctx context.Context) ([]string, error)
+	Cluster() string
+	ClusterQuery(query string) string
+	DistributedTableQuery(tableName string, wrapperName string) string
+	GetMaxValue(ctx context.Context, tableName string, column string) (string, error)
+	DeleteRows(ctx context.Context, tableName string, condition string) error
 }
 
 type Inserter interface {
@@ -26,8 +53,64 @@ type Inserter interface {
 }
 
 type Generator interface {
-	GenerateFileFromJSON(rows []string, fileName string) error
-	GenerateJSONlFromTable(tableName string) (string, error)
+	GenerateFileFromJSON(ctx context.Context, rows []string, fileName string) error
+	GenerateJSONlFromTable(ctx context.Context, tableName string) (string, error)
+	GenerateJSONlFromTableWhere(ctx context.Context, tableName string, condition string) (string, error)
+}
+
+// ColumnGenerator is implemented by generators that can export a subset of
+// the columns of a table, which copies need once the source schema changed
+// without the destination following it.
+type ColumnGenerator interface {
+	GenerateJSONlFromColumnsWhere(ctx context.Context, tableName string, columns []string, condition string) (string, error)
+}
+
+type SchemaCloner interface {
+	CreateTableQuery(ctx context.Context, sourceTable string, destinationTable string) (string, error)
+	CloneTable(ctx context.Context, sourceTable string, destinationTable string) error
+	CreateObjectQuery(ctx context.Context, sourceObject string, destinationObject string, rename func(string) string) (string, error)
+}
+
+// WatermarkStore persists the last copied cursor value of incremental tables.
+type WatermarkStore interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key string, value string) error
+}
+
+// Options holds the optional settings of a Replicator.
+type Options struct {
+	Tables      []models.TableConfig
+	Watermarks  WatermarkStore
+	Checkpoints CheckpointStore
+	// Restart discards the checkpoints of an interrupted run instead of resuming it.
+	Restart bool
+	// Concurrency is the number of tables replicated in parallel; values below one mean one.
+	Concurrency int
+	Order       models.TableOrder
+	// MaxSourceQueries caps the table reads running on the source at once
+	// across all workers. Zero leaves it unbounded.
+	MaxSourceQueries int
+	// Retries is the number of extra attempts given to a failed partition,
+	// chunk or copy of an unpartitioned table.
+	Retries int
+	// ChunkRows splits tables and partitions holding more rows into sorting
+	// key ranges of about this size. Zero copies them whole.
+	ChunkRows uint64
+	// Filter selects the tables taking part in a run; nil selects all of them.
+	Filter *filter.Filter
+	// Mapping renames tables on the destination; nil keeps the source names.
+	Mapping *mapping.Mapper
+	// Strict makes ReplicateDatabase return an error joining the errors of
+	// every failed table instead of only reporting them in the result.
+	Strict bool
+	// SchemaPolicy decides what happens when the columns of a source table
+	// changed; empty means models.SchemaWarn.
+	SchemaPolicy models.SchemaPolicy
+	// Redactor masks secrets in the errors put into results; nil keeps them.
+	Redactor *secret.Redactor
+	// DistributedSuffix, when set, creates a Distributed table named after
+	// every table with the suffix on a destination with a cluster.
+	DistributedSuffix string
 }
 
 type Replicator struct {
@@ -36,83 +119,290 @@ type Replicator struct {
 	logger      *zap.Logger
 	generator   Generator
 	inserter    Inserter
+	cloner      SchemaCloner
+	tables      map[string]models.TableConfig
+	watermarks  WatermarkStore
+	checkpoints CheckpointStore
+	restart     bool
+	concurrency int
+	order       models.TableOrder
+	sourceSlots chan struct{}
+	retries     int
+	chunkRows   uint64
+	strict      bool
+	filter      *filter.Filter
+	mapper      *mapping.Mapper
+	redactor    *secret.Redactor
+	schema      models.SchemaPolicy
+	distributed string
 }
 
-func NewReplicator(logger *zap.Logger, source DataSource, destination DataSource, generator Generator, inserter Inserter) *Replicator {
+func NewReplicator(logger *zap.Logger, source DataSource, destination DataSource, generator Generator, inserter Inserter, cloner SchemaCloner, options Options) *Replicator {
+	tables := make(map[string]models.TableConfig, len(options.Tables))
+	for _, table := range options.Tables {
+		tables[table.Name] = table
+	}
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var sourceSlots chan struct{}
+	if options.MaxSourceQueries > 0 {
+		sourceSlots = make(chan struct{}, options.MaxSourceQueries)
+	}
 	return &Replicator{
 		source:      source,
 		destination: destination,
 		logger:      logger,
 		generator:   generator,
 		inserter:    inserter,
+		cloner:      cloner,
+		tables:      tables,
+		watermarks:  options.Watermarks,
+		checkpoints: options.Checkpoints,
+		restart:     options.Restart,
+		concurrency: concurrency,
+		order:       options.Order,
+		sourceSlots: sourceSlots,
+		retries:     options.Retries,
+		chunkRows:   options.ChunkRows,
+		strict:      options.Strict,
+		filter:      options.Filter,
+		mapper:      options.Mapping,
+		redactor:    options.Redactor,
+		schema:      options.SchemaPolicy,
+		distributed: options.DistributedSuffix,
 	}
 }
 
-func (n *Replicator) ReplicateDatabase() error {
+// ReplicateDatabase copies every table of the source database and reports the
+// outcome of each. A failed table does not fail the run unless the replicator
+// is strict. When ctx is cancelled no new table or chunk is started, the
+// chunks in flight are finished so the destination stays consistent, and
+// ctx.Err() is returned together with the partial result.
+func (n *Replicator) ReplicateDatabase(ctx context.Context) (*models.ReplicationResult, error) {
+	result, err := NewJob(n.logger, n.concurrency, n.strict, n).Run(ctx)
+	if result == nil {
+		return nil, err
+	}
+	return &result.Databases[0], err
+}
+
+// prepare lists the tables to copy and the views, materialized views and
+// dictionaries to create afterwards, discards old checkpoints when restarting
+// and creates the destination database.
+func (n *Replicator) prepare(ctx context.Context) ([]string, []string, error) {
 	// Replication logic for the database
 	// We must fetch all the tables of the database (In our case we are normalizng JSON data)
 	// Create Respective jsonl files with data
 	// Insert in to the respective source tables.
 
-	n.logger.Info("Replication has begun")
+	n.logger.Info("Replication has begun", zap.String("database", n.source.Database()))
 
-	tables, err := n.source.GetAllTables(context.Background())
+	selected, _, err := n.listTables(ctx)
 
 	if err != nil {
 		n.logger.Error("Error fetching tables", zap.Error(err))
-		return err
+		return nil, nil, err
+	}
+	tables, objects, err := n.splitObjects(ctx, selected)
+	if err != nil {
+		n.logger.Error("Error fetching tables", zap.Error(err))
+		return nil, nil, err
 	}
 
-	err = n.destination.CreateDatabase(context.Background())
+	if n.restart && n.checkpoints != nil {
+		n.logger.Info("Discarding checkpoints of previous runs")
+		if err := n.checkpoints.Reset(ctx); err != nil {
+			n.logger.Error("Error discarding checkpoints", zap.Error(err))
+			return nil, nil, err
+		}
+	}
+
+	if err := n.checkCluster(ctx); err != nil {
+		n.logger.Error("Error checking destination cluster", zap.Error(err))
+		return nil, nil, err
+	}
+	err = n.destination.CreateDatabase(ctx)
 	if err != nil {
 		n.logger.Error("Error when creating database", zap.Error(err))
 	}
-	for _, table := range tables {
-		tableExists, err := n.destination.IsTableExists(context.Background(), table)
+	return n.orderTables(ctx, tables), objects, nil
+}
 
-		if err != nil {
-			n.logger.Error("Error checking if table exists", zap.String("table", table), zap.Error(err))
-			continue
+func (n *Replicator) replicateTable(ctx context.Context, table string, result *models.TableResult) error {
+	if err := n.replicateTableData(ctx, table, result); err != nil {
+		return err
+	}
+	// Only work in progress carries over to the next run.
+	return n.resetCheckpoints(ctx, table)
+}
+
+// replicateTableData copies table as planned, recording the plan in result.
+// The status is only set on success.
+func (n *Replicator) replicateTableData(ctx context.Context, table string, result *models.TableResult) error {
+	n.logger.Info("Replicating table", zap.String("table", table))
+	plan, err := n.planTable(ctx, table)
+	if err != nil {
+		return err
+	}
+	if err := n.planWrapper(ctx, &plan); err != nil {
+		return err
+	}
+	result.Destination = plan.Destination
+	result.Kind = plan.Kind
+	result.Action = plan.Action
+	result.Mode = plan.Mode
+	result.Reason = plan.Reason
+	result.Bytes = plan.EstimatedBytes
+	status := models.StatusCopied
+	switch plan.Action {
+	case models.ActionSkip, models.ActionUnsupported:
+		n.logSkip(plan)
+		if err := n.createWrapper(ctx, plan); err != nil {
+			return err
 		}
-		n.logger.Info("Replicating table", zap.String("table", table))
-		uRowCount, err := n.source.GetRowCount(context.Background(), table)
-		rowCount := int(uRowCount)
-		if err != nil {
-			n.logger.Error("Error fetching row count", zap.String("table", table), zap.Error(err))
-			continue
+		result.Status = models.StatusSkipped
+		return nil
+	case models.ActionCreate:
+		if plan.Kind != models.KindTable || plan.Engine == distributedEngine {
+			if err := n.createObject(ctx, plan); err != nil {
+				return err
+			}
+			result.Status = models.StatusCreated
+			return nil
 		}
-		if rowCount == 0 {
-			n.logger.Info("Skipping empty table", zap.String("table", table))
-			continue
+		if err := n.cloner.CloneTable(ctx, table, plan.Destination); err != nil {
+			return fmt.Errorf("creating table: %w", err)
 		}
+		status = models.StatusCreated
+	}
+	if err := n.createWrapper(ctx, plan); err != nil {
+		return err
+	}
+	columns, err := n.evolveSchema(ctx, table, plan, result)
+	if err != nil {
+		return err
+	}
+	ctx = withColumns(ctx, columns)
+	if plan.sourceRows == 0 && plan.Mode.Incremental() {
+		// An empty source table is only created.
+		result.Status = status
+		return nil
+	}
+	if err := n.loadTable(ctx, table, plan); err != nil {
+		return err
+	}
+	result.Status = status
+	return nil
+}
 
-		if tableExists {
+// copyTable copies the rows of table selected by plan.
+func (n *Replicator) copyTable(ctx context.Context, table string, plan tablePlan) error {
+	config, rowCount := plan.config, plan.sourceRows
+	if config.CursorColumn != "" {
+		return n.replicateIncremental(ctx, table, config)
+	}
+	partitioned, err := n.replicatePartitions(ctx, table)
+	if err != nil {
+		return err
+	}
+	switch {
+	case partitioned:
+		// Already copied partition by partition.
+	case n.chunkRows > 0 && rowCount > n.chunkRows:
+		err = n.replicateChunks(ctx, table, "", "")
+	default:
+		truncate := func(ctx context.Context) error {
+			return n.destination.TruncateTable(ctx, n.target(table))
+		}
+		err = n.retry(ctx, func() error {
+			return n.copyChunk(ctx, table, "full", truncate, func(ctx context.Context) error {
+				// The planned row counts differ, so whatever the destination
+				// holds is incomplete and copying on top of it would duplicate it.
+				if err := truncate(ctx); err != nil {
+					return fmt.Errorf("truncating table: %w", err)
+				}
+				return n.copyRows(ctx, table, n.target(table), "")
+			})
+		})
+	}
+	if err != nil {
+		return err
+	}
+	n.logger.Info("Successfully Replicated " + table)
+	return nil
+}
 
-			uCurrentRowCount, err := n.destination.GetRowCount(context.Background(), table)
-			if err != nil {
-				n.logger.Error("Error fetching row count", zap.String("table", table), zap.Error(err))
-				continue
-			}
-			currentRowCount := int(uCurrentRowCount)
+// copyRows transfers the rows of sourceTable matching condition to destinationTable.
+func (n *Replicator) copyRows(ctx context.Context, sourceTable string, destinationTable string, condition string) error {
+	release, err := n.acquireSource(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-			if currentRowCount == rowCount {
-				n.logger.Info("Skipping the table since current table contains all rows")
-				continue
-			}
+	var fileName string
+	if columns := copyColumns(ctx); columns != nil {
+		generator, ok := n.generator.(ColumnGenerator)
+		if !ok {
+			return fmt.Errorf("source schema changed and the generator cannot copy a subset of the columns")
 		}
-
-		fileName, err := n.generator.GenerateJSONlFromTable(table)
+		fileName, err = generator.GenerateJSONlFromColumnsWhere(ctx, sourceTable, columns, condition)
+	} else {
+		fileName, err = n.generator.GenerateJSONlFromTableWhere(ctx, sourceTable, condition)
+	}
+	if err != nil {
+		return fmt.Errorf("generating JSONL file: %w", err)
+	}
+	if counting, ok := n.inserter.(CountingInserter); ok {
+		rows, err := counting.InsertToClickhouseWithCount(ctx, n.logger, destinationTable, fileName, "JSONEachRow")
+		addRows(ctx, rows)
 		if err != nil {
-			n.logger.Error("Error generating JSONL file", zap.String("table", table), zap.Error(err))
-			continue
+			return fmt.Errorf("inserting to clickhouse: %w", err)
 		}
-		err = n.destination.CreateTableFromJSONData(context.Background(), table, "tuple()", utils.GetFileContent(fileName))
-		err = n.inserter.InsertToClickhouse(context.Background(), n.logger, table, fileName, "JSONEachRow")
-		if err != nil {
-			n.logger.Error("Error when Inserting to Clickhouse", zap.Error(err))
+		return nil
+	}
+	err = n.inserter.InsertToClickhouse(ctx, n.logger, destinationTable, fileName, "JSONEachRow")
+	if err != nil {
+		return fmt.Errorf("inserting to clickhouse: %w", err)
+	}
+	return nil
+}
+
+// listTables returns the source tables selected by the filter and the ones it
+// excluded, and logs both. The inner tables of materialized views are left out.
+func (n *Replicator) listTables(ctx context.Context) ([]string, []string, error) {
+	all, err := n.source.GetAllTables(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tables []string
+	for _, table := range all {
+		if isInnerTable(table) {
+			n.logger.Debug("Leaving inner table to its materialized view", zap.String("table", table))
 			continue
 		}
-		n.logger.Info("Successfully Replicated " + table)
+		tables = append(tables, table)
 	}
-	return nil
+	selected, excluded := n.filter.Apply(tables)
+	if err := n.mapper.Check(selected); err != nil {
+		return nil, nil, err
+	}
+	n.logger.Info("Resolved tables", zap.Strings("tables", selected), zap.Strings("excluded", excluded))
+	for _, table := range selected {
+		if destination := n.target(table); destination != table {
+			n.logger.Info("Renaming table on the destination", zap.String("table", table), zap.String("destination", destination))
+		}
+	}
+	return selected, excluded, nil
+}
+
+// target returns the destination name of a source table.
+func (n *Replicator) target(table string) string {
+	return n.mapper.Table(table)
+}
+
+func tableKey(database string, table string) string {
+	return database + "." + table
 }
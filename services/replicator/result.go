@@ -0,0 +1,56 @@
+package replicator
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prasannakumar414/click-replicator/models"
+	"go.uber.org/zap"
+)
+
+// CountingInserter is implemented by inserters that report how many rows
+// they wrote. Rows copied through other inserters are not counted.
+type CountingInserter interface {
+	InsertToClickhouseWithCount(ctx context.Context, logger *zap.Logger, table string, filePath string, format string) (uint64, error)
+}
+
+type rowCounterKey struct{}
+
+// withRowCounter returns a context under which copyRows adds the rows it
+// writes to the returned counter.
+func withRowCounter(ctx context.Context) (context.Context, *atomic.Uint64) {
+	counter := new(atomic.Uint64)
+	return context.WithValue(ctx, rowCounterKey{}, counter), counter
+}
+
+func addRows(ctx context.Context, rows uint64) {
+	if counter, ok := ctx.Value(rowCounterKey{}).(*atomic.Uint64); ok {
+		counter.Add(rows)
+	}
+}
+
+// replicateTableResult replicates table and reports the outcome.
+func (n *Replicator) replicateTableResult(ctx context.Context, table string) models.TableResult {
+	started := time.Now()
+	ctx, rows := withRowCounter(ctx)
+	result := models.TableResult{Table: table}
+	if err := n.replicateIsolated(ctx, table, &result); err != nil {
+		n.logger.Error("Error replicating table", zap.String("table", table), zap.Error(err))
+		result.Status = models.StatusFailed
+		result.Error = n.redactor.String(err.Error())
+	}
+	result.Rows = rows.Load()
+	result.Duration = models.Duration(time.Since(started))
+	return result
+}
+
+// notStarted reports a table the run never reached because ctx was cancelled.
+func notStarted(ctx context.Context, table string) models.TableResult {
+	return models.TableResult{
+		Table:  table,
+		Status: models.StatusFailed,
+		Reason: "not started",
+		Error:  context.Cause(ctx).Error(),
+	}
+}
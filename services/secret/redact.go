@@ -0,0 +1,152 @@
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Mask replaces secrets in redacted text.
+const Mask = "[REDACTED]"
+
+// Redactor masks a set of secret values in strings, errors and log entries.
+// A nil Redactor leaves everything unchanged.
+type Redactor struct {
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor for the non-empty values, or nil when there
+// are none.
+func NewRedactor(values ...string) *Redactor {
+	var secrets []string
+	for _, value := range values {
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	// Longer secrets first, so a secret containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, value := range secrets {
+		pairs = append(pairs, value, Mask)
+	}
+	return &Redactor{replacer: strings.NewReplacer(pairs...)}
+}
+
+// String masks the secrets in s.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Error wraps err so its message is redacted. errors.Is and errors.As still
+// see the original chain.
+func (r *Redactor) Error(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	return &redactedError{err: err, message: r.String(err.Error())}
+}
+
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string { return e.message }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Logger returns a logger writing through logger with every message and
+// field redacted.
+func (r *Redactor) Logger(logger *zap.Logger) *zap.Logger {
+	if r == nil {
+		return logger
+	}
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core, redactor: r}
+	}))
+}
+
+type redactingCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.fields(fields)), redactor: c.redactor}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.redactor.String(entry.Message)
+	return c.Core.Write(entry, c.fields(fields))
+}
+
+func (c *redactingCore) fields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = c.field(field)
+	}
+	return redacted
+}
+
+func (c *redactingCore) field(field zapcore.Field) zapcore.Field {
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = c.redactor.String(field.String)
+		return field
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			return zap.String(field.Key, c.redactor.String(err.Error()))
+		}
+	case zapcore.StringerType:
+		if stringer, ok := field.Interface.(fmt.Stringer); ok {
+			return zap.String(field.Key, c.redactor.String(stringer.String()))
+		}
+	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.ReflectType, zapcore.ByteStringType:
+		encoder := zapcore.NewMapObjectEncoder()
+		field.AddTo(encoder)
+		return zap.Any(field.Key, c.value(encoder.Fields[field.Key]))
+	}
+	return field
+}
+
+// value redacts the strings in a value produced by a MapObjectEncoder.
+func (c *redactingCore) value(value any) any {
+	switch v := value.(type) {
+	case string:
+		return c.redactor.String(v)
+	case []byte:
+		return c.redactor.String(string(v))
+	case []any:
+		redacted := make([]any, len(v))
+		for i, item := range v {
+			redacted[i] = c.value(item)
+		}
+		return redacted
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, item := range v {
+			redacted[key] = c.value(item)
+		}
+		return redacted
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	default:
+		return c.redactor.String(fmt.Sprintf("%+v", v))
+	}
+}
@@ -0,0 +1,100 @@
+// Package secret resolves credentials from the environment, files or
+// commands, and redacts them from logs and errors.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/prasannakumar414/click-replicator/models"
+)
+
+// Validate checks that exactly one source is named.
+func Validate(source models.SecretSource) error {
+	set := 0
+	if source.Env != "" {
+		set++
+	}
+	if source.File != "" {
+		set++
+	}
+	if len(source.Command) > 0 {
+		set++
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of env, file and command must be set")
+	}
+	return nil
+}
+
+// Resolve reads the secret named by source.
+func Resolve(ctx context.Context, source models.SecretSource) (string, error) {
+	if err := Validate(source); err != nil {
+		return "", err
+	}
+	switch {
+	case source.Env != "":
+		value, ok := os.LookupEnv(source.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", source.Env)
+		}
+		return value, nil
+	case source.File != "":
+		data, err := os.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("reading secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		cmd := exec.CommandContext(ctx, source.Command[0], source.Command[1:]...)
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			// The output may hold part of the secret, so it is left out.
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return "", fmt.Errorf("secret command %s exited with code %d", source.Command[0], exitErr.ExitCode())
+			}
+			return "", fmt.Errorf("running secret command %s: %w", source.Command[0], err)
+		}
+		return strings.TrimRight(stdout.String(), "\r\n"), nil
+	}
+}
+
+// ResolveConfig returns config with the password read from PasswordFrom.
+func ResolveConfig(ctx context.Context, config models.ClickHouseConfig) (models.ClickHouseConfig, error) {
+	if config.PasswordFrom == nil {
+		return config, nil
+	}
+	if config.Password != "" {
+		return config, fmt.Errorf("password and password_from are mutually exclusive")
+	}
+	password, err := Resolve(ctx, *config.PasswordFrom)
+	if err != nil {
+		return config, fmt.Errorf("resolving password: %w", err)
+	}
+	config.Password = password
+	config.PasswordFrom = nil
+	return config, nil
+}
+
+// ClientEnv returns the environment of the current process with the
+// credentials of config added for clickhouse-client, which reads them from
+// CLICKHOUSE_USER and CLICKHOUSE_PASSWORD. Passing them this way keeps them
+// out of command lines and of the logs quoting them.
+func ClientEnv(config models.ClickHouseConfig) []string {
+	env := os.Environ()
+	if config.Username != "" {
+		env = append(env, "CLICKHOUSE_USER="+config.Username)
+	}
+	if config.Password != "" {
+		env = append(env, "CLICKHOUSE_PASSWORD="+config.Password)
+	}
+	return env
+}
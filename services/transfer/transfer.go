@@ -0,0 +1,192 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/prasannakumar414/click-replicator/models"
+	"github.com/prasannakumar414/click-replicator/services/generator"
+	"go.uber.org/zap"
+)
+
+// URIScheme prefixes the handles returned by GenerateJSONlFromTable. The
+// handle names a source table instead of a file on disk.
+const URIScheme = "native://"
+
+// DefaultBlockSize is the number of rows sent to the destination per block.
+const DefaultBlockSize = 100000
+
+// Transfer copies rows between two ClickHouse servers over the native
+// protocol, reading from the source and appending to the destination block
+// by block. It implements both the replicator Generator and Inserter so it
+// can replace the clickhouse-client based path.
+type Transfer struct {
+	logger              *zap.Logger
+	source              driver.Conn
+	destination         driver.Conn
+	sourceDatabase      string
+	destinationDatabase string
+	blockSize           int
+	files               *generator.Generator
+}
+
+func NewTransfer(logger *zap.Logger, source driver.Conn, sourceDatabase string, destination driver.Conn, destinationDatabase string, blockSize int) *Transfer {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	return &Transfer{
+		logger:              logger,
+		source:              source,
+		destination:         destination,
+		sourceDatabase:      sourceDatabase,
+		destinationDatabase: destinationDatabase,
+		blockSize:           blockSize,
+		files:               generator.NewGenerator(logger, models.ClickHouseConfig{}, ""),
+	}
+}
+
+// GenerateFileFromJSON appends rows to fileName, one per line, with the
+// generator package.
+func (t *Transfer) GenerateFileFromJSON(ctx context.Context, rows []string, fileName string) error {
+	return t.files.GenerateFileFromJSON(ctx, rows, fileName)
+}
+
+// GenerateJSONlFromTable does not export anything; it returns a handle that
+// InsertToClickhouse resolves back to the source table.
+func (t *Transfer) GenerateJSONlFromTable(ctx context.Context, tableName string) (string, error) {
+	return t.GenerateJSONlFromTableWhere(ctx, tableName, "")
+}
+
+// GenerateJSONlFromTableWhere returns a handle for the rows of tableName that
+// match condition.
+func (t *Transfer) GenerateJSONlFromTableWhere(ctx context.Context, tableName string, condition string) (string, error) {
+	return t.GenerateJSONlFromColumnsWhere(ctx, tableName, nil, condition)
+}
+
+// GenerateJSONlFromColumnsWhere returns a handle for columns of the rows of
+// tableName that match condition, or every column when columns is empty.
+func (t *Transfer) GenerateJSONlFromColumnsWhere(ctx context.Context, tableName string, columns []string, condition string) (string, error) {
+	handle := URIScheme + t.sourceDatabase + "." + tableName
+	values := url.Values{}
+	if condition != "" {
+		values.Set("condition", condition)
+	}
+	if len(columns) > 0 {
+		values["column"] = columns
+	}
+	if len(values) > 0 {
+		handle += "?" + values.Encode()
+	}
+	return handle, nil
+}
+
+// InsertToClickhouse copies the source table named by handle into table on
+// the destination. The format argument is ignored since rows travel as native
+// blocks.
+func (t *Transfer) InsertToClickhouse(ctx context.Context, logger *zap.Logger, table string, handle string, format string) error {
+	_, err := t.InsertToClickhouseWithCount(ctx, logger, table, handle, format)
+	return err
+}
+
+// InsertToClickhouseWithCount is InsertToClickhouse returning the number of
+// rows copied.
+func (t *Transfer) InsertToClickhouseWithCount(ctx context.Context, logger *zap.Logger, table string, handle string, format string) (uint64, error) {
+	sourceTable, columns, condition, err := t.parseHandle(handle)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := t.CopyTable(ctx, sourceTable, table, columns, condition)
+	if err != nil {
+		return rows, err
+	}
+	logger.Info("Copied rows", zap.String("table", table), zap.Uint64("rows", rows))
+	return rows, nil
+}
+
+// CopyTable streams columns (every column when empty) of the rows of
+// sourceTable matching condition (a WHERE clause, or empty for the whole
+// table) into destinationTable and returns the number of rows copied.
+func (t *Transfer) CopyTable(ctx context.Context, sourceTable string, destinationTable string, columns []string, condition string) (uint64, error) {
+	selected := "*"
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = quoteIdentifier(column)
+		}
+		selected = strings.Join(quoted, ", ")
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s %s", selected, t.sourceDatabase, sourceTable, condition)
+	rows, err := t.source.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columnTypes := rows.ColumnTypes()
+	values := make([]any, len(columnTypes))
+	for i, columnType := range columnTypes {
+		values[i] = reflect.New(columnType.ScanType()).Interface()
+	}
+	names := make([]string, len(columnTypes))
+	for i, columnName := range rows.Columns() {
+		names[i] = quoteIdentifier(columnName)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s.%s (%s)", t.destinationDatabase, destinationTable, strings.Join(names, ", "))
+	batch, err := t.destination.PrepareBatch(ctx, insert)
+	if err != nil {
+		return 0, err
+	}
+	defer batch.Close()
+
+	var copied uint64
+	row := make([]any, len(values))
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			batch.Abort()
+			return copied, err
+		}
+		for i, value := range values {
+			row[i] = reflect.ValueOf(value).Elem().Interface()
+		}
+		if err := batch.Append(row...); err != nil {
+			batch.Abort()
+			return copied, err
+		}
+		copied++
+		if batch.Rows() >= t.blockSize {
+			if err := batch.Flush(); err != nil {
+				batch.Abort()
+				return copied, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		batch.Abort()
+		return copied, err
+	}
+	if err := batch.Send(); err != nil {
+		return copied, err
+	}
+	return copied, nil
+}
+
+func (t *Transfer) parseHandle(handle string) (string, []string, string, error) {
+	prefix := URIScheme + t.sourceDatabase + "."
+	if !strings.HasPrefix(handle, prefix) {
+		return "", nil, "", fmt.Errorf("invalid transfer handle %q", handle)
+	}
+	table, query, _ := strings.Cut(strings.TrimPrefix(handle, prefix), "?")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("invalid transfer handle %q: %w", handle, err)
+	}
+	return table, values["column"], values.Get("condition"), nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
@@ -0,0 +1,75 @@
+package watermark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/prasannakumar414/click-replicator/utils"
+)
+
+// DefaultFileName is used when no watermark file is configured.
+const DefaultFileName = "click_replicator_watermarks.json"
+
+// FileStore keeps the last copied cursor value of every table in a JSON file.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+// Get returns the watermark stored for key and whether one exists.
+func (s *FileStore) Get(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watermarks, err := s.read()
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := watermarks[key]
+	return value, ok, nil
+}
+
+// Set stores value as the watermark of key.
+func (s *FileStore) Set(ctx context.Context, key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watermarks, err := s.read()
+	if err != nil {
+		return err
+	}
+	watermarks[key] = value
+	return s.write(watermarks)
+}
+
+func (s *FileStore) read() (map[string]string, error) {
+	watermarks := make(map[string]string)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return watermarks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &watermarks); err != nil {
+		return nil, err
+	}
+	return watermarks, nil
+}
+
+func (s *FileStore) write(watermarks map[string]string) error {
+	data, err := json.MarshalIndent(watermarks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(s.path, data)
+}